@@ -0,0 +1,803 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"christopherharwell/project_monorepo/pkg/config"
+	"christopherharwell/project_monorepo/pkg/git"
+	"christopherharwell/project_monorepo/pkg/layout"
+	"christopherharwell/project_monorepo/pkg/local"
+	"christopherharwell/project_monorepo/pkg/lock"
+	"christopherharwell/project_monorepo/pkg/manifest"
+	"christopherharwell/project_monorepo/pkg/types"
+)
+
+func runInit(args []string) int {
+	fs, opts := newFlagSet("init", "", "Create the monorepo directory with its repos subdirectory and an initial commit.")
+	planOpts := addPlanFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if code, ok := checkPlanFlags(fs, planOpts); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return usageError(fs, "init takes no arguments")
+	}
+
+	a := &app{dir: opts.monorepo, out: os.Stdout}
+	err := a.runPlanned("init", planOpts, func() error {
+		if err := a.initMonorepo(); err != nil || a.plan != nil {
+			return err
+		}
+		fmt.Printf("Monorepo ready at %s\n", opts.monorepo)
+		return nil
+	})
+	if err != nil {
+		return fail("initializing monorepo", err)
+	}
+	return exitOK
+}
+
+func runFetch(args []string) int {
+	fs, opts := newFlagSet("fetch", "", "Fetch the repository lists of all configured providers, or of the ones named by\n--refresh-provider, store them in "+cacheFile+" and print what changed since the last\nfetch. A provider that fails keeps its cached list.")
+	refreshOpts := addRefreshFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return usageError(fs, "fetch takes no arguments")
+	}
+
+	a, err := newApp(opts)
+	if err != nil {
+		fmt.Println(err)
+		return exitFailure
+	}
+	a.refresh = *refreshOpts
+	a.refresh.all = len(refreshOpts.providers) == 0
+
+	if _, err := a.loadRepositories(context.Background()); err != nil {
+		return fail("fetching repositories", err)
+	}
+	return exitOK
+}
+
+func runList(args []string) int {
+	fs, opts := newFlagSet("list", "", "List the cached repositories that pass the filters, fetching them when the cache is empty.")
+	all := fs.Bool("all", false, "ignore the filter rules")
+	explain := fs.Bool("explain", false, "print which filter rule included or excluded each repository")
+	asJSON := fs.Bool("json", false, "print the repositories as JSON")
+	refreshOpts := addRefreshFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return usageError(fs, "list takes no arguments")
+	}
+
+	a, err := newApp(opts)
+	if err != nil {
+		fmt.Println(err)
+		return exitFailure
+	}
+	a.refresh = *refreshOpts
+
+	repos, err := a.repositories(context.Background(), !*all, *explain)
+	if err != nil {
+		return fail("listing repositories", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(repos); err != nil {
+			return fail("encoding repositories", err)
+		}
+		return exitOK
+	}
+
+	for _, r := range repos {
+		state := ""
+		if git.RepoExists(a.dir, r) {
+			state = " (added)"
+		}
+		name := r.FullPath
+		if name == "" {
+			name = r.Name
+		}
+		fmt.Printf("%-40s %-12s %s [%s]%s\n", git.RepoPath(r), r.Provider, name, r.DefaultBranch, state)
+	}
+	fmt.Printf("%d repositories\n", len(repos))
+	return exitOK
+}
+
+func runAdd(args []string) int {
+	fs, opts := newFlagSet("add", "[repository...]", "Add repositories to the monorepo. Repositories are named by their path in the monorepo,\ntheir full path or their name; without names they are selected interactively,\nor all repositories passing the filters are added with --auto.")
+	auto := fs.Bool("auto", false, "add every repository that passes the filters without prompting (overrides auto_mode)")
+	subtree := fs.Bool("subtree", false, "integrate as subtrees, or as submodules with --subtree=false (overrides use_subtree)")
+	explain := fs.Bool("explain", false, "print which filter rule included or excluded each repository")
+	writeManifest := fs.Bool("write-manifest", false, "record the selection in the manifest instead of adding the repositories")
+	fromManifest := fs.Bool("from-manifest", false, "add the manifest members missing from the monorepo and report drift")
+	fromLock := fs.String("from-lock", "", "add every repository of the given lockfile at its locked commit")
+	refreshOpts := addRefreshFlags(fs)
+	planOpts := addPlanFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if code, ok := checkPlanFlags(fs, planOpts); !ok {
+		return code
+	}
+	if *fromManifest && *fromLock != "" {
+		return usageError(fs, "--from-manifest and --from-lock cannot be combined")
+	}
+	if (*fromManifest || *fromLock != "") && fs.NArg() > 0 {
+		return usageError(fs, "repositories cannot be named together with --from-manifest or --from-lock")
+	}
+
+	a, err := newApp(opts)
+	if err != nil {
+		fmt.Println(err)
+		return exitFailure
+	}
+	a.refresh = *refreshOpts
+
+	switch {
+	case *fromManifest:
+		if err := a.runPlanned("add", planOpts, a.reconcileManifest); err != nil {
+			return fail("reconciling manifest", err)
+		}
+		return exitOK
+	case *fromLock != "":
+		err := a.runPlanned("add", planOpts, func() error {
+			return a.rebuildFromLock(*fromLock)
+		})
+		if err != nil {
+			return fail("rebuilding from "+*fromLock, err)
+		}
+		return exitOK
+	}
+
+	autoMode := boolOption(fs, "auto", *auto, a.cfg.AutoMode)
+	err = a.runPlanned("add", planOpts, func() error {
+		_, _, err := a.addSelected(addOptions{
+			names:         fs.Args(),
+			auto:          autoMode,
+			useSubtree:    boolOption(fs, "subtree", *subtree, a.cfg.UseSubtree),
+			askMethod:     !autoMode && !isSet(fs, "subtree"),
+			explain:       *explain,
+			writeManifest: *writeManifest,
+		})
+		return err
+	})
+	if err != nil {
+		return fail("adding repositories", err)
+	}
+	return exitOK
+}
+
+func runRemove(args []string) int {
+	fs, opts := newFlagSet("remove", "repository...", "Remove repositories from the monorepo, the lockfile and the manifest, and commit the removal.\nRepositories are named by their path below repos/ or their name.")
+	planOpts := addPlanFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if code, ok := checkPlanFlags(fs, planOpts); !ok {
+		return code
+	}
+	if fs.NArg() == 0 {
+		return usageError(fs, "remove needs at least one repository")
+	}
+
+	a, err := newApp(opts)
+	if err != nil {
+		fmt.Println(err)
+		return exitFailure
+	}
+	err = a.runPlanned("remove", planOpts, func() error {
+		return a.removeRepos(fs.Args())
+	})
+	if err != nil {
+		return fail("removing repositories", err)
+	}
+	return exitOK
+}
+
+// removeRepos removes the repositories named by queries from the monorepo,
+// the lockfile and the manifest in a single commit.
+func (a *app) removeRepos(queries []string) error {
+	if err := a.initMonorepo(); err != nil {
+		return err
+	}
+	lf, err := lock.Load(a.dir)
+	if err != nil {
+		return err
+	}
+	m, err := manifest.Load(a.dir)
+	hasManifest := err == nil
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	// Resolve every name first so a typo does not leave a partial removal behind
+	var repos []types.Repo
+	for _, query := range queries {
+		r, err := a.resolveMember(query, lf, m)
+		if err != nil {
+			return err
+		}
+		repos = append(repos, r)
+	}
+
+	var removed []string
+	for _, r := range repos {
+		lf.Remove(r.Path)
+		m.Remove(r.Path)
+		removed = append(removed, r.Path)
+		if a.plan != nil {
+			a.plan.Remove(r)
+			continue
+		}
+		if err := git.RemoveRepo(a.dir, r); err != nil {
+			return err
+		}
+		fmt.Fprintf(a.out, "Removed %s\n", git.RepoPath(r))
+	}
+
+	if a.plan != nil {
+		if fileExists(filepath.Join(a.dir, lock.FileName)) {
+			a.plan.Write(lock.FileName, "drop the removed repositories")
+		}
+		if hasManifest {
+			a.plan.Write(manifest.FileName, "drop the removed members")
+		}
+		return nil
+	}
+
+	files := []string{}
+	if len(lf.Repos) > 0 || fileExists(filepath.Join(a.dir, lock.FileName)) {
+		if err := lock.Save(a.dir, lf); err != nil {
+			return err
+		}
+		files = append(files, lock.FileName)
+	}
+	if hasManifest {
+		if err := manifest.Save(a.dir, m); err != nil {
+			return err
+		}
+		files = append(files, manifest.FileName)
+	}
+	return git.Commit(a.dir, "Remove "+strings.Join(removed, ", "), files...)
+}
+
+// resolveMember finds the repository named by query among the lockfile
+// entries, the manifest members and the directories below repos/.
+func (a *app) resolveMember(query string, lf lock.File, m manifest.Manifest) (types.Repo, error) {
+	candidates := map[string]types.Repo{}
+	for _, e := range lf.Select(query) {
+		candidates[e.Path] = e.Repo()
+	}
+	for _, member := range m.Members {
+		if strings.EqualFold(member.Path, query) || strings.EqualFold(member.Name, query) {
+			if _, ok := candidates[member.Path]; !ok {
+				candidates[member.Path] = member.Repo()
+			}
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		r := types.Repo{Name: filepath.Base(query), Path: filepath.ToSlash(query)}
+		if git.RepoExists(a.dir, r) {
+			return r, nil
+		}
+		return types.Repo{}, fmt.Errorf("no repository %q in the monorepo", query)
+	case 1:
+		for _, r := range candidates {
+			return r, nil
+		}
+	}
+
+	var paths []string
+	for p := range candidates {
+		paths = append(paths, p)
+	}
+	return types.Repo{}, fmt.Errorf("%q matches several repositories (%s), name one by its path", query, strings.Join(paths, ", "))
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func runUpdate(args []string) int {
+	fs, opts := newFlagSet("update", "[repository...]", "Move the repositories of "+lock.FileName+" to the tip of their branch, print the old..new\nrange and commit count of each and record the new commits. Without names, every\nlocked repository is updated.")
+	planOpts := addPlanFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if code, ok := checkPlanFlags(fs, planOpts); !ok {
+		return code
+	}
+
+	a, err := newApp(opts)
+	if err != nil {
+		fmt.Println(err)
+		return exitFailure
+	}
+	err = a.runPlanned("update", planOpts, func() error {
+		return a.updateLocked(fs.Args())
+	})
+	if err != nil {
+		return fail("updating repositories", err)
+	}
+	return exitOK
+}
+
+// updateLocked updates the locked repositories matching queries, or all of
+// them when there are none.
+func (a *app) updateLocked(queries []string) error {
+	if err := a.initMonorepo(); err != nil {
+		return err
+	}
+	lf, err := lock.Load(a.dir)
+	if err != nil {
+		return err
+	}
+	entries, err := selectLocked(lf, queries)
+	if err != nil {
+		return err
+	}
+
+	return errors.Join(
+		a.updateRepos(lockedRepos(entries, types.MethodSubtree), true),
+		a.updateRepos(lockedRepos(entries, types.MethodSubmodule), false),
+	)
+}
+
+func runPush(args []string) int {
+	fs, opts := newFlagSet("push", "[repository...]", "Push the subtrees of "+lock.FileName+" back to their branch. Without names, every\nlocked subtree is pushed. Submodules are pushed from within the submodule.")
+	planOpts := addPlanFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if code, ok := checkPlanFlags(fs, planOpts); !ok {
+		return code
+	}
+
+	a, err := newApp(opts)
+	if err != nil {
+		fmt.Println(err)
+		return exitFailure
+	}
+	err = a.runPlanned("push", planOpts, func() error {
+		return a.pushLocked(fs.Args())
+	})
+	if err != nil {
+		return fail("pushing repositories", err)
+	}
+	return exitOK
+}
+
+// pushLocked pushes the locked subtrees matching queries, or all of them
+// when there are none. Submodules are skipped.
+func (a *app) pushLocked(queries []string) error {
+	lf, err := lock.Load(a.dir)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", lock.FileName, err)
+	}
+	entries, err := selectLocked(lf, queries)
+	if err != nil {
+		return err
+	}
+
+	const submoduleReason = "submodules are pushed from within the submodule"
+	for _, e := range entries {
+		if e.Method != types.MethodSubmodule {
+			continue
+		}
+		if a.plan != nil {
+			a.plan.Skip(e.Repo(), submoduleReason)
+		} else {
+			fmt.Fprintf(a.out, "Skipping %s: %s\n", e.Path, submoduleReason)
+		}
+	}
+	subtrees := lockedRepos(entries, types.MethodSubtree)
+	if a.plan != nil {
+		a.plan.Push(subtrees)
+		return nil
+	}
+	if len(subtrees) == 0 {
+		return nil
+	}
+	return git.PushSubtrees(a.dir, withCloneURLs(subtrees, a.providers))
+}
+
+func runStatus(args []string) int {
+	fs, opts := newFlagSet("status", "", fmt.Sprintf("Show the locked repositories and compare the monorepo with %s and %s.\nExits with %d when there are uncommitted changes, missing repositories or drift.", manifest.FileName, lock.FileName, exitDifferences))
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return usageError(fs, "status takes no arguments")
+	}
+
+	a, err := newApp(opts)
+	if err != nil {
+		fmt.Println(err)
+		return exitFailure
+	}
+	inSync, err := a.status()
+	if err != nil {
+		return fail("reading status", err)
+	}
+	if !inSync {
+		return exitDifferences
+	}
+	return exitOK
+}
+
+// status prints the state of the monorepo without changing it and reports
+// whether it matches its lockfile and manifest.
+func (a *app) status() (bool, error) {
+	if !git.IsGitInitialized(a.dir) {
+		return false, fmt.Errorf("%s is not a monorepo, run init first", a.dir)
+	}
+
+	inSync := true
+	if err := git.VerifyCleanWorkingTree(a.dir); err != nil {
+		fmt.Fprintln(a.out, "The working tree has uncommitted changes.")
+		inSync = false
+	}
+
+	lf, err := lock.Load(a.dir)
+	if err != nil {
+		return false, err
+	}
+	fmt.Fprintf(a.out, "\n%d locked repositories:\n", len(lf.Repos))
+	for _, e := range lf.Repos {
+		state := ""
+		if !git.RepoExists(a.dir, e.Repo()) {
+			state = "  MISSING"
+			inSync = false
+		}
+		fmt.Fprintf(a.out, "  %-40s %-9s %s %s%s\n", git.RepoPath(e.Repo()), e.Method, git.ShortCommit(e.Commit), e.Branch, state)
+	}
+
+	m, err := manifest.Load(a.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(a.out, "\nNo %s in the monorepo.\n", manifest.FileName)
+		return inSync, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Fprintf(a.out, "\n%s:\n", manifest.FileName)
+	report, err := manifest.Check(a.dir, m, a.memberCloneURL)
+	if err != nil {
+		return false, err
+	}
+	manifest.PrintReport(a.out, report)
+	return inSync && report.InSync(), nil
+}
+
+func runScan(args []string) int {
+	fs, opts := newFlagSet("scan", "", "Scan a directory tree for local repositories and save the results. With --match, the\nrepositories are matched with the fetched ones to find local-only, orphaned and\nnever cloned repositories.")
+	baseDir := fs.String("base-dir", "", "root directory to scan (overrides base_dir)")
+	monorepoPath := fs.String("monorepo-path", "", "path to the monorepo, whose repositories are marked (overrides monorepo_path)")
+	output := fs.String("output", localReposFile, "file the scan results are saved to")
+	var ignore []string
+	fs.Func("ignore", "glob of directories not to scan, repeatable (replaces scan.ignore; \"\" scans everything)", func(pattern string) error {
+		if ignore == nil {
+			ignore = []string{}
+		}
+		if pattern != "" {
+			ignore = append(ignore, pattern)
+		}
+		return nil
+	})
+	maxDepth := fs.Int("max-depth", 0, "directory levels to visit below the base directory, 0 for no limit (overrides scan.max_depth)")
+	nested := fs.Bool("nested", false, "search repositories for nested repositories and submodules (overrides scan.nested)")
+	workers := fs.Int("workers", 0, "concurrent git probes, 0 for one per CPU (overrides scan.workers)")
+	match := fs.Bool("match", false, "match the local repositories with the cached or fetched repositories of the providers")
+	refreshOpts := addRefreshFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return usageError(fs, "scan takes no arguments")
+	}
+
+	// Scanning works without a configuration file when the directories are
+	// given as flags; matching needs the providers
+	a := &app{dir: opts.monorepo, out: os.Stdout}
+	if *match {
+		var err error
+		if a, err = newApp(opts); err != nil {
+			fmt.Println(err)
+			return exitFailure
+		}
+		a.refresh = *refreshOpts
+	} else {
+		cfg, err := config.LoadConfig(opts.configFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fail("loading config", err)
+		}
+		a.cfg = cfg
+	}
+	cfg := a.cfg
+
+	if isSet(fs, "base-dir") {
+		cfg.BaseDir = *baseDir
+	}
+	if isSet(fs, "monorepo-path") {
+		cfg.MonorepoPath = *monorepoPath
+	}
+	if ignore != nil {
+		cfg.Scan.Ignore = ignore
+	}
+	if isSet(fs, "max-depth") {
+		cfg.Scan.MaxDepth = *maxDepth
+	}
+	cfg.Scan.Nested = boolOption(fs, "nested", *nested, cfg.Scan.Nested)
+	if isSet(fs, "workers") {
+		cfg.Scan.Workers = *workers
+	}
+	localRepos, err := a.scanLocal(cfg.BaseDir, cfg.MonorepoPath, cfg.Scan, *output)
+	if err != nil {
+		return fail("scanning local repositories", err)
+	}
+	if *match {
+		if err := a.matchLocal(localRepos); err != nil {
+			return fail("matching local repositories", err)
+		}
+	}
+	return exitOK
+}
+
+func runAdopt(args []string) int {
+	fs := newCommandFlagSet("adopt", "directory...", "Initialize git repositories in the given directories, which scan lists as \"Not a Git repo\".\nA preview is shown and confirmed first. Adopted directories are recorded in the journal,\nand --undo removes their .git directory again as long as they have no commits.")
+	monorepo := fs.String("monorepo", defaultMonorepoDir, "path to the monorepo directory, which is never adopted")
+	yes := fs.Bool("yes", false, "do not ask for confirmation")
+	dryRun := fs.Bool("dry-run", false, "show the preview without changing anything")
+	undo := fs.Bool("undo", false, "revert the given adopted directories, or all of them without arguments")
+	journal := fs.String("journal", local.AdoptionsFile, "file recording the adopted directories")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if !*undo && fs.NArg() == 0 {
+		return usageError(fs, "adopt needs at least one directory")
+	}
+
+	a := &app{stdin: bufio.NewScanner(os.Stdin), out: os.Stdout}
+	action, preview := "initialize", func(paths []string) ([]local.Candidate, error) {
+		return local.PreviewAdopt(paths, *monorepo)
+	}
+	if *undo {
+		action = "revert"
+		preview = func(paths []string) ([]local.Candidate, error) {
+			return local.PreviewUndo(*journal, paths)
+		}
+	}
+
+	candidates, err := preview(fs.Args())
+	if err != nil {
+		return fail("previewing "+fs.Name(), err)
+	}
+	local.PrintCandidates(a.out, action, candidates)
+
+	ready := 0
+	for _, c := range candidates {
+		if c.Ready {
+			ready++
+		}
+	}
+	if ready == 0 || *dryRun {
+		return exitOK
+	}
+	if !*yes && !a.confirm(fmt.Sprintf("%s the %d listed directories?", strings.ToUpper(action[:1])+action[1:], ready)) {
+		fmt.Println("Nothing was changed.")
+		return exitOK
+	}
+
+	if *undo {
+		if _, err := local.Undo(candidates, *journal); err != nil {
+			return fail("reverting directories", err)
+		}
+		return exitOK
+	}
+	if _, err := local.Adopt(candidates, *journal); err != nil {
+		return fail("adopting directories", err)
+	}
+	fmt.Printf("Recorded in %s; run \"%s adopt --undo\" to revert.\n", *journal, programName())
+	return exitOK
+}
+
+func runImport(args []string) int {
+	fs, opts := newFlagSet("import", "[directory...]", "Import local repositories, such as the ones scan reports as local-only, into the monorepo.\nBy default each is added as a subtree from its directory with its full history. With\n--submodule, a remote is created for it first, its branch is pushed there and the\nremote is added as a submodule. The directory is recorded as the origin in "+lock.FileName+"\nand, when there is one, "+manifest.FileName+".")
+	submodule := fs.Bool("submodule", false, "create a remote for each repository and add it as a submodule instead of a subtree")
+	remote := fs.String("remote", "", "with --submodule, URL of the remote to create, {name} is the repository name (overrides import_remote)")
+	remoteName := fs.String("remote-name", "origin", "with --submodule, name of the new remote in the local repository")
+	fromScan := fs.String("from-scan", "", "import the local-only repositories of the given scan results, e.g. "+localReposFile)
+	planOpts := addPlanFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if code, ok := checkPlanFlags(fs, planOpts); !ok {
+		return code
+	}
+	if fs.NArg() == 0 && *fromScan == "" {
+		return usageError(fs, "import needs at least one directory or --from-scan")
+	}
+
+	a, err := newApp(opts)
+	if err != nil {
+		fmt.Println(err)
+		return exitFailure
+	}
+	remoteURL := a.cfg.ImportRemote
+	if isSet(fs, "remote") {
+		remoteURL = *remote
+	}
+	if *submodule && remoteURL == "" {
+		return usageError(fs, "--submodule needs --remote or import_remote in %s", opts.configFile)
+	}
+	if !*submodule && isSet(fs, "remote") {
+		return usageError(fs, "--remote requires --submodule")
+	}
+
+	paths := fs.Args()
+	if *fromScan != "" {
+		scanned, err := local.LoadReposData(*fromScan)
+		if err != nil {
+			return fail("reading "+*fromScan, err)
+		}
+		for _, r := range scanned {
+			if r.IsGitRepo && len(r.Remotes) == 0 && !r.IsInMonorepo {
+				paths = append(paths, r.Path)
+			}
+		}
+		if len(paths) == 0 {
+			fmt.Printf("%s lists no local-only repositories.\n", *fromScan)
+			return exitOK
+		}
+	}
+
+	err = a.runPlanned("import", planOpts, func() error {
+		return a.importLocal(paths, importOptions{submodule: *submodule, remote: remoteURL, remoteName: *remoteName})
+	})
+	if err != nil {
+		return fail("importing repositories", err)
+	}
+	return exitOK
+}
+
+// importOptions controls how local repositories are imported.
+type importOptions struct {
+	// submodule adds the repositories as submodules of a new remote instead of subtrees
+	submodule bool
+
+	// remote is the URL of the new remote, where {name} is the repository name
+	remote string
+
+	// remoteName is the name of the new remote in the local repository
+	remoteName string
+}
+
+// importLocal imports the local repositories at paths into the monorepo and
+// records them, with their directory as origin, in the lockfile and the
+// manifest. Directories that cannot be imported, and repositories whose path
+// is already taken, are skipped.
+func (a *app) importLocal(paths []string, opts importOptions) error {
+	if opts.submodule && len(paths) > 1 && !strings.Contains(opts.remote, "{name}") {
+		return fmt.Errorf("the remote %s has no {name} placeholder, so the %d repositories would share it", opts.remote, len(paths))
+	}
+	var repos []types.Repo
+	locals := map[string]local.LocalRepo{}
+	for _, p := range paths {
+		l, err := local.Inspect(p, a.dir)
+		if err != nil {
+			return err
+		}
+		r := l.Repo()
+		if problem := l.ImportProblem(); problem != "" {
+			a.skipImport(r, problem)
+			continue
+		}
+		if l.Modified > 0 || l.Untracked > 0 || l.Stashes > 0 {
+			fmt.Fprintf(a.out, "Warning: %s has uncommitted or stashed changes, only its commits are imported\n", l.Path)
+		}
+		if opts.submodule {
+			r.SSHURL = strings.ReplaceAll(opts.remote, "{name}", l.Name)
+			if abs, ok := local.LocalPath(r.SSHURL); ok {
+				r.SSHURL = abs
+			}
+		}
+		repos = append(repos, r)
+		locals[l.Path] = l
+	}
+
+	recorded, err := a.recordedRepos(repos)
+	if err != nil {
+		return err
+	}
+	repos, collisions, err := layout.Assign(repos, a.cfg.PathLayout, recorded)
+	if err != nil {
+		return fmt.Errorf("error assigning repository paths: %w", err)
+	}
+	layout.PrintCollisions(a.out, collisions)
+	if err := a.initMonorepo(); err != nil {
+		return err
+	}
+
+	var pending []types.Repo
+	for _, r := range repos {
+		if git.RepoExists(a.dir, r) {
+			a.skipImport(r, git.RepoPath(r)+" already exists")
+			continue
+		}
+		pending = append(pending, r)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if opts.submodule {
+		var created []types.Repo
+		var errs []error
+		for _, r := range pending {
+			if a.plan != nil {
+				a.plan.Remote(r)
+			} else if err := local.CreateRemote(locals[r.Origin], opts.remoteName, r.SSHURL); err != nil {
+				errs = append(errs, fmt.Errorf("error creating the remote of %s: %w", r.Origin, err))
+				continue
+			}
+			created = append(created, r)
+		}
+		if len(created) == 0 {
+			return errors.Join(errs...)
+		}
+		return errors.Join(append(errs, a.importAndRecord(created, false))...)
+	}
+	return a.importAndRecord(pending, true)
+}
+
+// skipImport reports that the local repository r is not imported and why.
+func (a *app) skipImport(r types.Repo, reason string) {
+	if a.plan != nil {
+		a.plan.Skip(r, reason)
+		return
+	}
+	fmt.Fprintf(a.out, "Skipping %s: %s\n", r.Origin, reason)
+}
+
+// importAndRecord imports repos with their full history and records them in
+// the lockfile and, when the monorepo has one, the manifest.
+func (a *app) importAndRecord(repos []types.Repo, useSubtree bool) error {
+	if a.plan != nil {
+		if a.plan.Add(repos, useSubtree) > 0 {
+			a.plan.Write(lock.FileName, "pin the imported repositories")
+			if manifestExists(a.dir) {
+				a.plan.Write(manifest.FileName, "record the imported members")
+			}
+		}
+		return nil
+	}
+
+	imported, err := git.ImportRepos(a.dir, repos, useSubtree)
+	if lockErr := a.recordLock(imported, useSubtree); lockErr != nil {
+		return lockErr
+	}
+	if manifestErr := a.recordMembers(imported, useSubtree); manifestErr != nil {
+		return manifestErr
+	}
+	return err
+}
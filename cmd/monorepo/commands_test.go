@@ -0,0 +1,27 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestImportLocalNeedsNamePlaceholder(t *testing.T) {
+	a := &app{dir: t.TempDir(), out: io.Discard}
+	tests := []struct {
+		paths   []string
+		opts    importOptions
+		wantErr bool
+	}{
+		{paths: []string{"notes", "scripts"}, opts: importOptions{submodule: true, remote: "/srv/git/shared.git"}, wantErr: true},
+		{paths: []string{"notes", "scripts"}, opts: importOptions{submodule: true, remote: "/srv/git/{name}.git"}},
+		{paths: []string{"notes"}, opts: importOptions{submodule: true, remote: "/srv/git/notes.git"}},
+		{paths: []string{"notes", "scripts"}},
+	}
+	for _, tt := range tests {
+		err := a.importLocal(tt.paths, tt.opts)
+		if isPlaceholderErr := err != nil && strings.Contains(err.Error(), "{name}"); isPlaceholderErr != tt.wantErr {
+			t.Errorf("importLocal(%q, %+v) = %v, want a {name} error: %v", tt.paths, tt.opts, err, tt.wantErr)
+		}
+	}
+}
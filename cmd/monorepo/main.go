@@ -1,132 +1,422 @@
 // Package main provides the entry point for the monorepo management tool.
 // This tool helps manage multiple Git repositories by integrating them into a single monorepo,
-// supporting both GitHub and GitLab repositories, as well as local repositories.
+// supporting GitHub, GitLab, Gitea/Forgejo, Bitbucket and Azure DevOps repositories, plain git remotes, as well as local repositories.
+//
+// Each operation is a subcommand with its own flags, which override the
+// settings of config.json:
+//
+//	monorepo_aggregator <command> [flags] [arguments]
+//
+// Run "monorepo_aggregator help" for the list of commands.
 package main
 
 import (
-	"context"
+	"bufio"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"christopherharwell/project_monorepo/pkg/config"
 	"christopherharwell/project_monorepo/pkg/git"
-	"christopherharwell/project_monorepo/pkg/github"
-	"christopherharwell/project_monorepo/pkg/gitlab"
-	"christopherharwell/project_monorepo/pkg/local"
+	"christopherharwell/project_monorepo/pkg/plan"
+	"christopherharwell/project_monorepo/pkg/provider"
 	"christopherharwell/project_monorepo/pkg/types"
+
+	// Provider implementations register themselves with the provider registry
+	_ "christopherharwell/project_monorepo/pkg/azuredevops"
+	_ "christopherharwell/project_monorepo/pkg/bitbucket"
+	_ "christopherharwell/project_monorepo/pkg/gitea"
+	_ "christopherharwell/project_monorepo/pkg/github"
+	_ "christopherharwell/project_monorepo/pkg/gitlab"
+	_ "christopherharwell/project_monorepo/pkg/remotes"
+)
+
+const (
+	// defaultConfigFile is the default path to the configuration file
+	defaultConfigFile = "config.json"
+
+	// cacheFile is the path where fetched repository metadata is cached
+	cacheFile = "repo_cache.json"
+
+	// defaultMonorepoDir is the default directory the repositories are integrated into
+	defaultMonorepoDir = "monorepo"
 )
 
+// Exit codes shared by all commands.
 const (
-	// configFile is the default path to the configuration file
-	configFile = "config.json"
+	// exitOK reports success
+	exitOK = 0
+
+	// exitFailure reports that the operation failed
+	exitFailure = 1
+
+	// exitUsage reports invalid flags or arguments
+	exitUsage = 2
+
+	// exitDifferences reports that status found differences from the manifest or lockfile
+	exitDifferences = 3
 )
 
+// command is a subcommand of the tool.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+func commands() []command {
+	return []command{
+		{"init", "Create the monorepo directory and its git repository", runInit},
+		{"fetch", "Fetch the repository lists of all providers into the cache", runFetch},
+		{"list", "List the cached repositories that pass the filters", runList},
+		{"add", "Add repositories to the monorepo", runAdd},
+		{"remove", "Remove repositories from the monorepo", runRemove},
+		{"update", "Move locked repositories to the tip of their branch", runUpdate},
+		{"push", "Push subtree changes back to their repositories", runPush},
+		{"status", "Compare the monorepo with its manifest and lockfile", runStatus},
+		{"scan", "Scan a directory tree for local repositories", runScan},
+		{"adopt", "Initialize git repositories in chosen local directories, or undo that", runAdopt},
+		{"import", "Import local repositories into the monorepo with their full history", runImport},
+	}
+}
+
 // main is the entry point of the application.
-// It loads the configuration, handles local repositories if configured,
-// fetches remote repositories, and processes them according to the configuration.
+// It dispatches to the subcommand named by the first argument. Without
+// arguments it runs the workflow configured in config.json: scan (when
+// scan_local is set), add, then update and push as enabled.
 func main() {
-	cfg, err := config.LoadConfig(configFile)
-	if err != nil {
-		fmt.Printf("Error loading config: %v\n", err)
-		os.Exit(1)
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 {
+		return runDefault()
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage(os.Stdout)
+		return exitOK
+	}
+	for _, c := range commands() {
+		if c.name == args[0] {
+			return c.run(args[1:])
+		}
 	}
 
-	ctx := context.Background()
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+	usage(os.Stderr)
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	name := programName()
+	fmt.Fprintf(w, "Usage: %s <command> [flags] [arguments]\n\nCommands:\n", name)
+	for _, c := range commands() {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nRun \"%s <command> --help\" for the flags of a command.\n", name)
+	fmt.Fprintf(w, "Without a command, the workflow configured in %s runs.\n", defaultConfigFile)
+	fmt.Fprintf(w, "\nExit codes: %d success, %d failure, %d invalid usage, %d status found differences.\n", exitOK, exitFailure, exitUsage, exitDifferences)
+}
+
+func programName() string {
+	return filepath.Base(os.Args[0])
+}
 
-	if cfg.ScanLocal {
-		handleLocalRepos(cfg)
+// globalOptions are the flags every command reading the configuration accepts.
+type globalOptions struct {
+	configFile string
+	monorepo   string
+}
+
+// newFlagSet creates the flag set of a command, with the flags shared by
+// every command already defined.
+func newFlagSet(name string, arguments string, summary string) (*flag.FlagSet, *globalOptions) {
+	fs := newCommandFlagSet(name, arguments, summary)
+	opts := &globalOptions{}
+	fs.StringVar(&opts.configFile, "config", defaultConfigFile, "path to the configuration file")
+	fs.StringVar(&opts.monorepo, "monorepo", defaultMonorepoDir, "path to the monorepo directory")
+	return fs, opts
+}
+
+// newCommandFlagSet creates the flag set of a command without the shared
+// flags, for commands that do not read the configuration.
+func newCommandFlagSet(name string, arguments string, summary string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] %s\n\n%s\n\nFlags:\n", programName(), name, arguments, summary)
+		fs.PrintDefaults()
 	}
+	return fs
+}
 
-	repos := getRepositories(ctx, cfg)
-	selected := selectRepositories(repos, cfg.AutoMode)
-	
-	if err := git.InitMonorepo(); err != nil {
-		fmt.Printf("Error initializing monorepo: %v\n", err)
-		os.Exit(1)
+// parseFlags parses args into fs. Flags may follow the arguments, as in
+// "remove api --dry-run"; everything after "--" is an argument. It returns
+// false together with the exit code when the command must stop, either
+// because help was requested or because the flags are invalid.
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return exitOK, false
+			}
+			return exitUsage, false
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			break
+		}
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
 	}
 
-	processRepositories(selected, cfg)
+	// Parsing a lone "--" leaves exactly the collected arguments in fs.Args()
+	if err := fs.Parse(append([]string{"--"}, positional...)); err != nil {
+		return exitUsage, false
+	}
+	return exitOK, true
 }
 
-// handleLocalRepos scans and processes local repositories based on the configuration.
-// It prints information about found repositories and saves the data to a file.
-//
-// Parameters:
-//   - cfg: The application configuration
-func handleLocalRepos(cfg types.Config) {
-	fmt.Println("Scanning local repositories...")
-	if cfg.BaseDir == "" || cfg.MonorepoPath == "" {
-		fmt.Println("Error: 'base_dir' and 'monorepo_path' must be set in config.json when scan_local is true")
-		os.Exit(1)
+// isSet reports whether the flag name was given on the command line.
+func isSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+// boolOption returns value when the flag name was given on the command line
+// and the configuration setting fallback otherwise.
+func boolOption(fs *flag.FlagSet, name string, value bool, fallback bool) bool {
+	if isSet(fs, name) {
+		return value
 	}
+	return fallback
+}
+
+// usageError reports invalid arguments of the command using fs.
+func usageError(fs *flag.FlagSet, format string, args ...interface{}) int {
+	fmt.Fprintf(fs.Output(), format+"\n\n", args...)
+	fs.Usage()
+	return exitUsage
+}
+
+// planOptions are the flags of the commands that can print their plan
+// instead of changing the monorepo.
+type planOptions struct {
+	dryRun bool
+	asJSON bool
+}
+
+// addPlanFlags defines --dry-run and --json on fs.
+func addPlanFlags(fs *flag.FlagSet) *planOptions {
+	opts := &planOptions{}
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "print what would be done without changing the monorepo")
+	fs.BoolVar(&opts.asJSON, "json", false, "with --dry-run, print the plan as JSON and send progress to stderr")
+	return opts
+}
+
+// refreshOptions selects the cached provider listings that are fetched again
+// although they have not expired.
+type refreshOptions struct {
+	// all refreshes every provider
+	all bool
 
-	localRepos, err := local.SearchRepos(cfg.BaseDir, cfg.MonorepoPath)
+	// providers are the names of the providers to refresh
+	providers []string
+}
+
+// addRefreshFlags defines --refresh and --refresh-provider on fs.
+func addRefreshFlags(fs *flag.FlagSet) *refreshOptions {
+	opts := &refreshOptions{}
+	fs.BoolVar(&opts.all, "refresh", false, "fetch every provider again even if its cached listing has not expired")
+	fs.Func("refresh-provider", "fetch the named provider again even if its cached listing has not expired, repeatable", func(name string) error {
+		opts.providers = append(opts.providers, name)
+		return nil
+	})
+	return opts
+}
+
+// checkPlanFlags rejects --json without --dry-run.
+func checkPlanFlags(fs *flag.FlagSet, opts *planOptions) (int, bool) {
+	if opts.asJSON && !opts.dryRun {
+		return usageError(fs, "--json requires --dry-run"), false
+	}
+	return exitOK, true
+}
+
+// fail prints an error and returns exitFailure.
+func fail(context string, err error) int {
+	fmt.Printf("Error %s: %v\n", context, err)
+	return exitFailure
+}
+
+// app holds the state shared by the commands: the configuration, the
+// monorepo location and the configured providers with the cache settings
+// of their listings. During a dry run, plan collects the changes instead of
+// making them.
+type app struct {
+	cfg       types.Config
+	dir       string
+	providers []provider.Provider
+	stdin     *bufio.Scanner
+	plan      *plan.Plan
+
+	// out receives progress messages and prompts; a JSON plan moves them to
+	// stderr so stdout only carries the plan
+	out io.Writer
+
+	// cacheKeys are the account keys of the providers, by provider name
+	cacheKeys map[string]string
+
+	// cacheTTL is how long a cached listing is used before it is fetched again
+	cacheTTL time.Duration
+
+	// refresh selects the listings fetched again regardless of their age
+	refresh refreshOptions
+}
+
+// newApp loads the configuration named by opts and creates its providers.
+func newApp(opts *globalOptions) (*app, error) {
+	cfg, err := config.LoadConfig(opts.configFile)
 	if err != nil {
-		fmt.Printf("Error scanning local repositories: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("error loading config: %w", err)
 	}
 
-	local.PrintRepos(localRepos)
-	if err := local.SaveReposData(localRepos, "local_repos.json"); err != nil {
-		fmt.Printf("Error saving local repository data: %v\n", err)
+	providerConfigs := config.Providers(cfg)
+	providers, err := provider.NewAll(providerConfigs)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring providers: %w", err)
+	}
+	cacheKeys := map[string]string{}
+	for i, p := range providers {
+		cacheKeys[p.Name()] = provider.AccountKey(providerConfigs[i])
 	}
+	ttl, err := config.CacheTTL(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error loading config: %w", err)
+	}
+	return &app{
+		cfg:       cfg,
+		dir:       opts.monorepo,
+		providers: providers,
+		stdin:     bufio.NewScanner(os.Stdin),
+		out:       os.Stdout,
+		cacheKeys: cacheKeys,
+		cacheTTL:  ttl,
+	}, nil
+}
 
-	if !cfg.AutoMode {
-		promptContinue()
+// runPlanned runs op, or only records the changes op would make and prints
+// them when opts.dryRun is set. For a JSON plan, progress and prompts go to
+// stderr while op runs so stdout only carries the plan.
+func (a *app) runPlanned(command string, opts *planOptions, op func() error) error {
+	if !opts.dryRun {
+		return op()
 	}
+	a.plan = plan.New(command, a.dir)
+	defer func() { a.plan = nil }()
+
+	if !opts.asJSON {
+		if err := op(); err != nil {
+			return err
+		}
+		plan.Print(a.out, *a.plan)
+		return nil
+	}
+
+	out := a.out
+	a.out = os.Stderr
+	defer func() { a.out = out }()
+	if err := op(); err != nil {
+		return err
+	}
+	return plan.WriteJSON(out, *a.plan)
 }
 
-// getRepositories fetches repositories from both GitHub and GitLab based on the configuration.
-//
-// Parameters:
-//   - ctx: Context for the operation
-//   - cfg: The application configuration
-//
-// Returns:
-//   - []types.Repo: A slice of repositories from both sources
-func getRepositories(ctx context.Context, cfg types.Config) []types.Repo {
-	var allRepos []types.Repo
-	
-	// Fetch from GitHub
-	githubRepos := github.FetchRepos(ctx, cfg.GitHubToken)
-	allRepos = append(allRepos, githubRepos...)
-	
-	// Fetch from GitLab
-	gitlabRepos := gitlab.FetchRepos(ctx, cfg.GitLabToken)
-	allRepos = append(allRepos, gitlabRepos...)
-	
-	return allRepos
-}
-
-// selectRepositories filters repositories based on the auto mode setting.
-// In auto mode, all repositories are selected. Otherwise, it prompts for user selection.
-//
-// Parameters:
-//   - repos: The list of repositories to select from
-//   - autoMode: Whether to automatically select all repositories
-//
-// Returns:
-//   - []types.Repo: The selected repositories
-func selectRepositories(repos []types.Repo, autoMode bool) []types.Repo {
-	if autoMode {
-		return repos
+// initMonorepo creates the monorepo, or plans its creation during a dry run.
+func (a *app) initMonorepo() error {
+	if a.plan != nil {
+		a.plan.Init()
+		return nil
 	}
-	
-	// TODO: Implement interactive selection
-	return repos
+	return git.InitMonorepo(a.dir)
 }
 
-// processRepositories handles the integration of selected repositories into the monorepo.
-//
-// Parameters:
-//   - selected: The repositories to process
-//   - cfg: The application configuration
-func processRepositories(selected []types.Repo, cfg types.Config) {
-	// TODO: Implement repository processing
+// runDefault runs the workflow configured in config.json, as the tool did
+// before it had commands: scan, add, update and push.
+func runDefault() int {
+	a, err := newApp(&globalOptions{configFile: defaultConfigFile, monorepo: defaultMonorepoDir})
+	if err != nil {
+		fmt.Println(err)
+		return exitFailure
+	}
+
+	if a.cfg.ScanLocal {
+		localRepos, err := a.scanLocal(a.cfg.BaseDir, a.cfg.MonorepoPath, a.cfg.Scan, localReposFile)
+		if err != nil {
+			return fail("scanning local repositories", err)
+		}
+		if err := a.matchLocal(localRepos); err != nil {
+			return fail("matching local repositories", err)
+		}
+		if !a.cfg.AutoMode {
+			a.promptContinue()
+		}
+	}
+
+	selected, useSubtree, err := a.addSelected(addOptions{
+		auto:       a.cfg.AutoMode,
+		useSubtree: a.cfg.UseSubtree,
+		askMethod:  !a.cfg.AutoMode,
+	})
+	if err != nil {
+		return fail("processing repositories", err)
+	}
+
+	if !useSubtree {
+		return exitOK
+	}
+	if a.cfg.UpdateMode {
+		if err := a.updateRepos(selected, true); err != nil {
+			return fail("updating repositories", err)
+		}
+	}
+	if a.cfg.PushMode {
+		if err := git.PushSubtrees(a.dir, withCloneURLs(selected, a.providers)); err != nil {
+			return fail("pushing repositories", err)
+		}
+	}
+	return exitOK
+}
+
+// confirm asks a yes/no question and reports whether the answer was yes.
+func (a *app) confirm(question string) bool {
+	fmt.Fprintf(a.out, "%s [y/N] ", question)
+	if !a.stdin.Scan() {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(a.stdin.Text())) {
+	case "y", "yes":
+		return true
+	}
+	return false
 }
 
 // promptContinue waits for user input before proceeding with remote repository scanning.
-func promptContinue() {
-	fmt.Print("Press Enter to continue with remote repository scanning or Ctrl+C to exit...")
-	fmt.Scanln()
-} 
\ No newline at end of file
+func (a *app) promptContinue() {
+	fmt.Fprint(a.out, "Press Enter to continue with remote repository scanning or Ctrl+C to exit...")
+	a.stdin.Scan()
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// captureOutput runs fn with os.Stdout and os.Stderr redirected to files and
+// returns what was written to each.
+func captureOutput(t *testing.T, fn func()) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	stdout, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+	stderr, err := os.Create(filepath.Join(dir, "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stderr.Close()
+
+	oldStdout, oldStderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = stdout, stderr
+	defer func() { os.Stdout, os.Stderr = oldStdout, oldStderr }()
+	fn()
+
+	out, err := os.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	errOut, err := os.ReadFile(stderr.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out), string(errOut)
+}
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		args   []string
+		code   int
+		ok     bool
+		rest   []string
+		dryRun bool
+		name   string
+	}{
+		{args: []string{"api", "web"}, ok: true, rest: []string{"api", "web"}},
+		{args: []string{"api", "--dry-run"}, ok: true, rest: []string{"api"}, dryRun: true},
+		{args: []string{"--name", "x", "api", "--dry-run", "web"}, ok: true, rest: []string{"api", "web"}, dryRun: true, name: "x"},
+		{args: []string{"api", "--name=x", "web"}, ok: true, rest: []string{"api", "web"}, name: "x"},
+		{args: []string{"api", "--", "--dry-run", "web"}, ok: true, rest: []string{"api", "--dry-run", "web"}},
+		{args: []string{"--", "-api"}, ok: true, rest: []string{"-api"}},
+		{args: []string{"--help"}, code: exitOK},
+		{args: []string{"api", "--bogus"}, code: exitUsage},
+		{args: []string{"--name"}, code: exitUsage},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		dryRun := fs.Bool("dry-run", false, "")
+		name := fs.String("name", "", "")
+
+		code, ok := parseFlags(fs, tt.args)
+		if code != tt.code || ok != tt.ok {
+			t.Errorf("parseFlags(%q) = %d, %v, want %d, %v", tt.args, code, ok, tt.code, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if rest := fs.Args(); !slices.Equal(rest, tt.rest) {
+			t.Errorf("parseFlags(%q) left arguments %q, want %q", tt.args, rest, tt.rest)
+		}
+		if *dryRun != tt.dryRun || *name != tt.name {
+			t.Errorf("parseFlags(%q) set dry-run %v name %q, want %v %q", tt.args, *dryRun, *name, tt.dryRun, tt.name)
+		}
+	}
+}
+
+// setGitIdentity sets the identity git commits with for the test.
+func setGitIdentity(t *testing.T) {
+	for _, env := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(env, "Test")
+	}
+	for _, env := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(env, "test@example.com")
+	}
+}
+
+// newBareRepo creates the bare repository name.git in dir with one commit
+// on main and returns its path.
+func newBareRepo(t *testing.T, dir string, name string) string {
+	t.Helper()
+	bare := filepath.Join(dir, name+".git")
+	work := filepath.Join(t.TempDir(), name)
+	git := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q", "--bare", "-b", "main", bare)
+	git("init", "-q", "-b", "main", work)
+	// Subtrees cannot be added from a commit without files
+	if err := os.WriteFile(filepath.Join(work, "README"), []byte(name+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("-C", work, "add", "README")
+	git("-C", work, "commit", "-q", "-m", "Initial commit")
+	git("-C", work, "push", "-q", bare, "main")
+	return bare
+}
+
+func TestExitCodes(t *testing.T) {
+	t.Chdir(t.TempDir())
+	setGitIdentity(t)
+	if err := os.WriteFile(defaultConfigFile, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cases run in order against the same monorepo
+	tests := []struct {
+		args  []string
+		setup func() error
+		want  int
+	}{
+		{args: []string{"help"}, want: exitOK},
+		{args: []string{"init", "--help"}, want: exitOK},
+		{args: []string{"bogus"}, want: exitUsage},
+		{args: []string{"init", "extra"}, want: exitUsage},
+		{args: []string{"init", "--bogus"}, want: exitUsage},
+		{args: []string{"init", "--json"}, want: exitUsage},
+		{args: []string{"status", "--config", "missing.json"}, want: exitFailure},
+		{args: []string{"status", "--monorepo", "mono"}, want: exitFailure},
+		{args: []string{"init", "--monorepo", "mono"}, want: exitOK},
+		{args: []string{"status", "--monorepo", "mono"}, want: exitOK},
+		{
+			args: []string{"status", "--monorepo", "mono"},
+			setup: func() error {
+				return os.WriteFile(filepath.Join("mono", "notes.txt"), []byte("draft\n"), 0644)
+			},
+			want: exitDifferences,
+		},
+	}
+	for _, tt := range tests {
+		if tt.setup != nil {
+			if err := tt.setup(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var code int
+		stdout, stderr := captureOutput(t, func() { code = run(tt.args) })
+		if code != tt.want {
+			t.Errorf("run(%q) = %d, want %d\nstdout: %s\nstderr: %s", tt.args, code, tt.want, stdout, stderr)
+		}
+	}
+}
+
+func TestRunPlanned(t *testing.T) {
+	tests := []struct {
+		opts planOptions
+		// inPlan is whether the plan is printed to stdout
+		inPlan bool
+		// progressOn is where the progress of the operation goes
+		progressOn string
+	}{
+		{opts: planOptions{}, progressOn: "stdout"},
+		{opts: planOptions{dryRun: true}, inPlan: true, progressOn: "stdout"},
+		{opts: planOptions{dryRun: true, asJSON: true}, inPlan: true, progressOn: "stderr"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		a := &app{dir: filepath.Join(t.TempDir(), "mono"), out: &out}
+		ran := false
+		var err error
+		_, stderr := captureOutput(t, func() {
+			err = a.runPlanned("init", &tt.opts, func() error {
+				ran = true
+				fmt.Fprintln(a.out, "progress")
+				if a.plan != nil {
+					a.plan.Init()
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			t.Fatalf("runPlanned(%+v): %v", tt.opts, err)
+		}
+		if !ran {
+			t.Errorf("runPlanned(%+v) did not run the operation", tt.opts)
+		}
+		if a.plan != nil {
+			t.Errorf("runPlanned(%+v) left the plan set", tt.opts)
+		}
+		if a.out != &out {
+			t.Errorf("runPlanned(%+v) did not restore the output", tt.opts)
+		}
+
+		stdout := out.String()
+		progress := map[string]string{"stdout": stdout, "stderr": stderr}
+		if !strings.Contains(progress[tt.progressOn], "progress") {
+			t.Errorf("runPlanned(%+v): expected the progress on %s\nstdout: %s\nstderr: %s", tt.opts, tt.progressOn, stdout, stderr)
+		}
+		if tt.opts.asJSON {
+			var p struct {
+				Command string `json:"command"`
+			}
+			if err := json.Unmarshal([]byte(stdout), &p); err != nil || p.Command != "init" {
+				t.Errorf("runPlanned(%+v): stdout is not the JSON plan: %v\n%s", tt.opts, err, stdout)
+			}
+		}
+		if inPlan := strings.Contains(stdout, "init"); inPlan != tt.inPlan {
+			t.Errorf("runPlanned(%+v): plan printed %v, want %v\n%s", tt.opts, inPlan, tt.inPlan, stdout)
+		}
+	}
+
+	// A failing operation restores the output
+	a := &app{dir: t.TempDir(), out: io.Discard}
+	err := a.runPlanned("init", &planOptions{dryRun: true, asJSON: true}, func() error {
+		return fmt.Errorf("failed")
+	})
+	if err == nil || a.out != io.Discard {
+		t.Errorf("runPlanned returned %v and left the output on %v", err, a.out)
+	}
+}
+
+func TestCheckPlanFlags(t *testing.T) {
+	tests := []struct {
+		opts planOptions
+		code int
+		ok   bool
+	}{
+		{opts: planOptions{}, code: exitOK, ok: true},
+		{opts: planOptions{dryRun: true}, code: exitOK, ok: true},
+		{opts: planOptions{dryRun: true, asJSON: true}, code: exitOK, ok: true},
+		{opts: planOptions{asJSON: true}, code: exitUsage},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		if code, ok := checkPlanFlags(fs, &tt.opts); code != tt.code || ok != tt.ok {
+			t.Errorf("checkPlanFlags(%+v) = %d, %v, want %d, %v", tt.opts, code, ok, tt.code, tt.ok)
+		}
+	}
+}
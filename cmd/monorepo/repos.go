@@ -0,0 +1,755 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"christopherharwell/project_monorepo/pkg/cache"
+	"christopherharwell/project_monorepo/pkg/filter"
+	"christopherharwell/project_monorepo/pkg/git"
+	"christopherharwell/project_monorepo/pkg/httputil"
+	"christopherharwell/project_monorepo/pkg/inventory"
+	"christopherharwell/project_monorepo/pkg/layout"
+	"christopherharwell/project_monorepo/pkg/local"
+	"christopherharwell/project_monorepo/pkg/lock"
+	"christopherharwell/project_monorepo/pkg/manifest"
+	"christopherharwell/project_monorepo/pkg/provider"
+	"christopherharwell/project_monorepo/pkg/types"
+)
+
+// localReposFile is where the results of a local scan are saved by default
+const localReposFile = "local_repos.json"
+
+// getRepositories returns the repositories of every configured provider,
+// fetching the listings that expired or were asked to be refreshed. A
+// provider that cannot be fetched only causes a warning; its previous
+// listing is used when there is one.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//
+// Returns:
+//   - []types.Repo: A slice of repositories from all providers
+//   - error: Any error that occurred while reading the cache or selecting the providers to refresh
+func (a *app) getRepositories(ctx context.Context) ([]types.Repo, error) {
+	repos, err := a.loadRepositories(ctx)
+	var fetchErr *fetchError
+	if errors.As(err, &fetchErr) {
+		fmt.Fprintf(a.out, "Warning: %v\n", err)
+		return repos, nil
+	}
+	return repos, err
+}
+
+// fetchError reports the providers whose listing could not be fetched.
+type fetchError struct {
+	err error
+}
+
+func (e *fetchError) Error() string { return e.err.Error() }
+
+func (e *fetchError) Unwrap() error { return e.err }
+
+// loadRepositories is getRepositories, with the providers that failed
+// reported as a *fetchError.
+func (a *app) loadRepositories(ctx context.Context) ([]types.Repo, error) {
+	if err := a.checkRefresh(); err != nil {
+		return nil, err
+	}
+	if len(a.providers) == 0 {
+		fmt.Fprintln(a.out, "Warning: no providers configured, set tokens, \"providers\" or \"remotes\" in config.json")
+		return nil, nil
+	}
+	f, err := cache.Load(cacheFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", cacheFile, err)
+	}
+
+	stale := a.staleProviders(f, time.Now())
+	if len(stale) == 0 {
+		return f.Repos(a.providerNames()), nil
+	}
+	err = a.refreshCache(ctx, &f, stale)
+	return f.Repos(a.providerNames()), err
+}
+
+// checkRefresh fails when a.refresh names a provider that is not configured.
+func (a *app) checkRefresh() error {
+	names := a.providerNames()
+	for _, name := range a.refresh.providers {
+		if !slices.Contains(names, name) {
+			return fmt.Errorf("no provider named %q is configured", name)
+		}
+	}
+	return nil
+}
+
+// providerNames returns the instance names of the configured providers.
+func (a *app) providerNames() []string {
+	var names []string
+	for _, p := range a.providers {
+		names = append(names, p.Name())
+	}
+	return names
+}
+
+// staleProviders returns the providers whose listing is fetched: the ones
+// selected by a.refresh and those whose cached listing expired or was
+// fetched with another account.
+func (a *app) staleProviders(f cache.File, now time.Time) []provider.Provider {
+	var stale []provider.Provider
+	for _, p := range a.providers {
+		name := p.Name()
+		if a.refresh.all || slices.Contains(a.refresh.providers, name) || !f.Fresh(name, a.cacheKeys[name], a.cacheTTL, now) {
+			stale = append(stale, p)
+		}
+	}
+	return stale
+}
+
+// refreshCache fetches the listings of providers into f, prints what changed
+// since the previous listing of each and saves f, except during a dry run.
+// Listings of providers that are no longer configured are dropped on saving.
+// Providers that support conditional requests revalidate the pages of their
+// previous listing. Providers that fail keep their previous listing and are
+// reported in the returned *fetchError.
+func (a *app) refreshCache(ctx context.Context, f *cache.File, providers []provider.Provider) error {
+	fmt.Fprintf(a.out, "Fetching repos from %d provider(s)...\n", len(providers))
+	now := time.Now()
+	pageCaches := map[string]*httputil.PageCache{}
+	for _, p := range providers {
+		if pc, ok := p.(provider.PageCacher); ok {
+			var pages map[string]httputil.Page
+			if previous := f.Providers[p.Name()]; previous.Key == a.cacheKeys[p.Name()] {
+				pages = previous.Pages
+			}
+			pageCaches[p.Name()] = httputil.NewPageCache(pages)
+			pc.SetPageCache(pageCaches[p.Name()])
+		}
+	}
+
+	var errs []error
+	fetched := 0
+	for _, res := range provider.FetchEach(ctx, providers) {
+		name := res.Provider.Name()
+		previous, cached := f.Providers[name]
+		if res.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, res.Err))
+			if cached {
+				fmt.Fprintf(a.out, "Using the cached listing of %s\n", name)
+			}
+			continue
+		}
+		if cached && (previous.Key == "" || previous.Key == a.cacheKeys[name]) {
+			cache.PrintChanges(a.out, name, cache.Diff(previous.Repos, res.Repos))
+		}
+		f.Set(name, a.cacheKeys[name], res.Repos, now)
+		if pages := pageCaches[name]; pages != nil {
+			f.SetPages(name, pages.Pages())
+			requests, notModified := pages.Stats()
+			fmt.Fprintf(a.out, "%s: %d of %d request(s) not modified, served from cache\n", name, notModified, requests)
+		}
+		fetched++
+	}
+
+	if fetched > 0 && a.plan == nil {
+		names := a.providerNames()
+		f.Retain(names)
+		if err := cache.Save(cacheFile, *f); err != nil {
+			fmt.Fprintf(a.out, "Warning: could not write %s: %v\n", cacheFile, err)
+		} else {
+			fmt.Fprintf(a.out, "Cached %d repositories in %s\n", len(f.Repos(names)), cacheFile)
+		}
+	}
+	if len(errs) > 0 {
+		return &fetchError{errors.Join(errs...)}
+	}
+	return nil
+}
+
+// repositories returns the known repositories with their monorepo paths
+// assigned, reporting path collisions. When applyFilters is set, the filter
+// rules of the configuration are applied and explain prints their decisions;
+// during a dry run the excluded repositories are listed as skipped.
+func (a *app) repositories(ctx context.Context, applyFilters bool, explain bool) ([]types.Repo, error) {
+	repoFilter, err := filter.New(a.cfg.Filters)
+	if err != nil {
+		return nil, fmt.Errorf("error in filters: %w", err)
+	}
+
+	repos, err := a.getRepositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded, err := a.recordedRepos(repos)
+	if err != nil {
+		return nil, err
+	}
+	repos, collisions, err := layout.Assign(repos, a.cfg.PathLayout, recorded)
+	if err != nil {
+		return nil, fmt.Errorf("error assigning repository paths: %w", err)
+	}
+	layout.PrintCollisions(a.out, collisions)
+	if !applyFilters {
+		return repos, nil
+	}
+
+	filtered, decisions := repoFilter.Apply(repos)
+	if explain {
+		filter.PrintDecisions(a.out, decisions)
+	} else if excluded := len(repos) - len(filtered); excluded > 0 {
+		fmt.Fprintf(a.out, "Filters excluded %d of %d repositories (run with --explain for details)\n", excluded, len(repos))
+	}
+	if a.plan != nil {
+		for _, d := range decisions {
+			if !d.Included {
+				a.plan.Skip(d.Repo, d.Reason)
+			}
+		}
+	}
+	return filtered, nil
+}
+
+// recordedRepos returns the repositories the lockfile and the manifest place
+// in the monorepo, so layout.Assign keeps them at their paths. Their URLs are
+// recorded without credentials and, with a git_host set, on that host, so
+// they are translated back to the URLs of the matching repos.
+func (a *app) recordedRepos(repos []types.Repo) ([]types.Repo, error) {
+	var recorded []types.Repo
+	lf, err := lock.Load(a.dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range lf.Repos {
+		recorded = append(recorded, e.Repo())
+	}
+	m, err := manifest.Load(a.dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, member := range m.Members {
+		recorded = append(recorded, member.Repo())
+	}
+
+	listed := map[string]string{}
+	for _, r := range repos {
+		listed[git.StripCredentials(provider.CloneURL(a.providers, r))] = r.SSHURL
+	}
+	for i, r := range recorded {
+		if u, ok := listed[r.SSHURL]; ok {
+			recorded[i].SSHURL = u
+		}
+	}
+	return recorded, nil
+}
+
+// withCloneURLs returns a copy of repos whose SSHURL is replaced by the clone
+// URL of the provider each repository was listed from, so credentials are
+// only added right before git runs.
+func withCloneURLs(repos []types.Repo, providers []provider.Provider) []types.Repo {
+	resolved := make([]types.Repo, len(repos))
+	for i, r := range repos {
+		r.SSHURL = provider.CloneURL(providers, r)
+		resolved[i] = r
+	}
+	return resolved
+}
+
+// selectRepositories filters repositories based on the auto mode setting.
+// In auto mode, all repositories are selected. Otherwise, it prompts for user selection
+// and falls back to all repositories when nothing was chosen.
+//
+// Parameters:
+//   - repos: The list of repositories to select from
+//   - autoMode: Whether to automatically select all repositories
+//   - stdin: Scanner used to read the selection
+//   - out: Writer the prompts are printed to
+//
+// Returns:
+//   - []types.Repo: The selected repositories
+func selectRepositories(repos []types.Repo, autoMode bool, stdin *bufio.Scanner, out io.Writer) []types.Repo {
+	if autoMode {
+		return repos
+	}
+
+	selected := interactiveSelectRepos(repos, stdin, out)
+	if len(selected) == 0 {
+		fmt.Fprintln(out, "No selection made. Defaulting to all repositories.")
+		return repos
+	}
+	return selected
+}
+
+// interactiveSelectRepos lists the repositories and reads repository names
+// until an empty line is entered. An input matches a repository by its path
+// in the monorepo, its full path or, failing those, its name.
+func interactiveSelectRepos(repos []types.Repo, stdin *bufio.Scanner, out io.Writer) []types.Repo {
+	fmt.Fprintln(out, "Select repositories to include (type name, enter empty to finish):")
+	for i, r := range repos {
+		label := r.Name
+		if r.FullPath != "" {
+			label = fmt.Sprintf("%s [%s]", r.Name, r.FullPath)
+		}
+		fmt.Fprintf(out, "[%d] %s -> %s (default branch: %s)\n", i, label, git.RepoPath(r), r.DefaultBranch)
+	}
+
+	var selected []types.Repo
+	for {
+		fmt.Fprint(out, "Repo name (or enter to finish): ")
+		if !stdin.Scan() {
+			break
+		}
+		input := strings.TrimSpace(stdin.Text())
+		if input == "" {
+			break
+		}
+		if r, ok := findRepo(repos, input); ok {
+			selected = append(selected, r)
+		} else {
+			fmt.Fprintf(out, "No repository matches %q\n", input)
+		}
+	}
+	return selected
+}
+
+// findRepo returns the repository whose path, full path or name equals input,
+// in that order of preference, so repositories sharing a name stay selectable.
+func findRepo(repos []types.Repo, input string) (types.Repo, bool) {
+	matchers := []func(types.Repo) string{
+		func(r types.Repo) string { return r.Path },
+		func(r types.Repo) string { return r.FullPath },
+		func(r types.Repo) string { return r.Name },
+	}
+	for _, field := range matchers {
+		for _, r := range repos {
+			if value := field(r); value != "" && strings.EqualFold(value, input) {
+				return r, true
+			}
+		}
+	}
+	return types.Repo{}, false
+}
+
+// selectIntegrationMethod asks whether repositories should be added as
+// submodules or subtrees. Any answer other than "1" or "2" keeps the current choice.
+func selectIntegrationMethod(useSubtree bool, stdin *bufio.Scanner, out io.Writer) bool {
+	fmt.Fprintln(out, "Choose integration method: [1] Submodule, [2] Subtree")
+	fmt.Fprint(out, "Enter choice (1 or 2): ")
+	if !stdin.Scan() {
+		return useSubtree
+	}
+	switch strings.TrimSpace(stdin.Text()) {
+	case "1":
+		return false
+	case "2":
+		return true
+	}
+	return useSubtree
+}
+
+// addOptions controls which repositories add selects and how they are integrated.
+type addOptions struct {
+	// names are the repositories requested on the command line; they bypass the filters
+	names []string
+
+	// auto selects every repository that passes the filters without asking
+	auto bool
+
+	// useSubtree integrates the repositories as subtrees instead of submodules
+	useSubtree bool
+
+	// askMethod prompts for the integration method, with useSubtree as the default
+	askMethod bool
+
+	// explain prints the decision of the filter rules for each repository
+	explain bool
+
+	// writeManifest records the selection in the manifest instead of adding it
+	writeManifest bool
+}
+
+// addSelected selects repositories and adds them to the monorepo, or records
+// them in the manifest when opts.writeManifest is set.
+//
+// Parameters:
+//   - opts: The selection and integration options
+//
+// Returns:
+//   - []types.Repo: The selected repositories
+//   - bool: Whether they were integrated as subtrees
+//   - error: Any error that occurred while selecting or adding repositories
+func (a *app) addSelected(opts addOptions) ([]types.Repo, bool, error) {
+	repos, err := a.repositories(context.Background(), len(opts.names) == 0, opts.explain)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var selected []types.Repo
+	if len(opts.names) > 0 {
+		for _, name := range opts.names {
+			r, ok := findRepo(repos, name)
+			if !ok {
+				return nil, false, fmt.Errorf("no repository matches %q", name)
+			}
+			selected = append(selected, r)
+		}
+	} else {
+		selected = selectRepositories(repos, opts.auto, a.stdin, a.out)
+	}
+
+	if err := a.initMonorepo(); err != nil {
+		return nil, false, fmt.Errorf("error initializing monorepo: %w", err)
+	}
+
+	useSubtree := opts.useSubtree
+	if opts.askMethod {
+		useSubtree = selectIntegrationMethod(useSubtree, a.stdin, a.out)
+	}
+
+	if opts.writeManifest {
+		return selected, useSubtree, a.saveManifest(selected, useSubtree)
+	}
+	return selected, useSubtree, a.addAndLock(withCloneURLs(selected, a.providers), useSubtree)
+}
+
+// saveManifest records the selected repositories and integration method in
+// the manifest at the monorepo root and commits it. An existing manifest
+// keeps its other members; selected members replace those at the same path.
+func (a *app) saveManifest(selected []types.Repo, useSubtree bool) error {
+	m, err := manifest.Load(a.dir)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		m = manifest.FromRepos(selected, useSubtree)
+	case err != nil:
+		return err
+	default:
+		for _, r := range selected {
+			m.Record(r, useSubtree)
+		}
+	}
+	if a.plan != nil {
+		a.plan.Write(manifest.FileName, fmt.Sprintf("record %d member(s)", len(selected)))
+		return nil
+	}
+	if err := manifest.Save(a.dir, m); err != nil {
+		return err
+	}
+	if err := git.Commit(a.dir, "Update monorepo manifest", manifest.FileName); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Wrote %d member(s) to %s\n", len(m.Members), filepath.Join(a.dir, manifest.FileName))
+	return nil
+}
+
+// reconcileManifest adds the manifest members missing from the monorepo with
+// their recorded integration method, at their locked commit when the
+// lockfile has one. Unmanaged paths and drifted members are only reported,
+// and make the reconciliation fail so they get attention. During a dry run
+// they are listed as skipped instead.
+func (a *app) reconcileManifest() error {
+	if err := a.initMonorepo(); err != nil {
+		return err
+	}
+	m, err := manifest.Load(a.dir)
+	if err != nil {
+		return err
+	}
+
+	report, err := manifest.Check(a.dir, m, a.memberCloneURL)
+	if err != nil {
+		return err
+	}
+	manifest.PrintReport(a.out, report)
+
+	lf, err := lock.Load(a.dir)
+	if err != nil {
+		return err
+	}
+	var subtrees, submodules []types.Repo
+	for _, member := range report.Missing {
+		r := member.Repo()
+		if e, ok := lf.Find(member.Path); ok && e.URL == git.StripCredentials(a.memberCloneURL(member)) && e.Method == member.Method {
+			r.Commit = e.Commit
+		}
+		if member.Method == types.MethodSubtree {
+			subtrees = append(subtrees, r)
+		} else {
+			submodules = append(submodules, r)
+		}
+	}
+	if err := a.addAndLock(withCloneURLs(subtrees, a.providers), true); err != nil {
+		return err
+	}
+	if err := a.addAndLock(withCloneURLs(submodules, a.providers), false); err != nil {
+		return err
+	}
+
+	if a.plan != nil {
+		for _, d := range report.Drift {
+			a.plan.Skip(d.Member.Repo(), d.Problem)
+		}
+		for _, p := range report.Unmanaged {
+			a.plan.Skip(types.Repo{Name: path.Base(p), Path: p}, "not listed in "+manifest.FileName)
+		}
+		return nil
+	}
+	if len(report.Unmanaged) > 0 || len(report.Drift) > 0 {
+		return fmt.Errorf("%d unmanaged path(s) and %d drifted member(s) need to be resolved by hand", len(report.Unmanaged), len(report.Drift))
+	}
+	return nil
+}
+
+func (a *app) memberCloneURL(member manifest.Member) string {
+	return provider.CloneURL(a.providers, member.Repo())
+}
+
+// addAndLock adds repos to the monorepo and pins the added ones in the
+// lockfile and, when the monorepo has one, lists them in the manifest.
+func (a *app) addAndLock(repos []types.Repo, useSubtree bool) error {
+	if len(repos) == 0 {
+		return nil
+	}
+	if a.plan != nil {
+		if a.plan.Add(repos, useSubtree) > 0 {
+			a.plan.Write(lock.FileName, "pin the added repositories")
+			if manifestExists(a.dir) {
+				a.plan.Write(manifest.FileName, "record the added members")
+			}
+		}
+		return nil
+	}
+	added, err := git.AddRepos(a.dir, repos, useSubtree)
+	if lockErr := a.recordLock(added, useSubtree); lockErr != nil {
+		return lockErr
+	}
+	if manifestErr := a.recordMembers(added, useSubtree); manifestErr != nil {
+		return manifestErr
+	}
+	return err
+}
+
+// recordLock pins repos at their Commit in the lockfile at the monorepo root
+// and commits it.
+func (a *app) recordLock(repos []types.Repo, useSubtree bool) error {
+	if len(repos) == 0 {
+		return nil
+	}
+	lf, err := lock.Load(a.dir)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, r := range repos {
+		lf.Record(r, useSubtree, now)
+	}
+	if err := lock.Save(a.dir, lf); err != nil {
+		return err
+	}
+	return git.Commit(a.dir, "Update "+lock.FileName, lock.FileName)
+}
+
+// recordMembers lists repos in the manifest at the monorepo root and commits
+// it. Without a manifest nothing is recorded, and members already listed at
+// the path of a repository are kept as they are.
+func (a *app) recordMembers(repos []types.Repo, useSubtree bool) error {
+	m, err := manifest.Load(a.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	listed := map[string]bool{}
+	for _, member := range m.Members {
+		listed[member.Path] = true
+	}
+	recorded := 0
+	for _, r := range repos {
+		if !listed[r.Path] {
+			m.Record(r, useSubtree)
+			recorded++
+		}
+	}
+	if recorded == 0 {
+		return nil
+	}
+	if err := manifest.Save(a.dir, m); err != nil {
+		return err
+	}
+	return git.Commit(a.dir, "Update monorepo manifest", manifest.FileName)
+}
+
+// manifestExists reports whether the monorepo at dir has a manifest.
+func manifestExists(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, manifest.FileName))
+	return err == nil
+}
+
+// lockedRepos returns the repositories of entries integrated with method.
+func lockedRepos(entries []lock.Entry, method string) []types.Repo {
+	var repos []types.Repo
+	for _, e := range entries {
+		if e.Method == method {
+			repos = append(repos, e.Repo())
+		}
+	}
+	return repos
+}
+
+// selectLocked returns the lockfile entries matching any of queries, or all
+// entries when there are no queries.
+func selectLocked(lf lock.File, queries []string) ([]lock.Entry, error) {
+	if len(lf.Repos) == 0 {
+		return nil, fmt.Errorf("%s lists no repositories", lock.FileName)
+	}
+	if len(queries) == 0 {
+		return lf.Repos, nil
+	}
+
+	var entries []lock.Entry
+	seen := map[string]bool{}
+	for _, query := range queries {
+		matches := lf.Select(query)
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no repository %q in %s", query, lock.FileName)
+		}
+		for _, e := range matches {
+			if !seen[e.Path] {
+				seen[e.Path] = true
+				entries = append(entries, e)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// updateRepos moves repos to the tip of their branch, printing the old..new
+// range and commit count of each, and records the new commits in the lockfile.
+// Repositories without a Commit start from their locked commit.
+func (a *app) updateRepos(repos []types.Repo, useSubtree bool) error {
+	if len(repos) == 0 {
+		return nil
+	}
+	lf, err := lock.Load(a.dir)
+	if err != nil {
+		return err
+	}
+	resolved := withCloneURLs(repos, a.providers)
+	for i, r := range resolved {
+		if e, ok := lf.Find(r.Path); ok && r.Commit == "" {
+			resolved[i].Commit = e.Commit
+		}
+	}
+	if a.plan != nil {
+		if a.plan.Update(resolved, useSubtree) > 0 {
+			a.plan.Write(lock.FileName, "record the updated commits")
+		}
+		return nil
+	}
+
+	var updates []git.Update
+	if useSubtree {
+		updates, err = git.UpdateSubtrees(a.dir, resolved)
+	} else {
+		updates, err = git.UpdateSubmodules(a.dir, resolved)
+	}
+
+	var updated []types.Repo
+	for _, u := range updates {
+		updated = append(updated, u.Repo)
+	}
+	return errors.Join(err, a.recordLock(updated, useSubtree))
+}
+
+// rebuildFromLock adds every repository of the lockfile at lockFile at its
+// locked commit, so a monorepo can be reproduced exactly. Repositories that
+// already exist are skipped. The lockfile is then stored in the monorepo.
+func (a *app) rebuildFromLock(lockFile string) error {
+	lf, err := lock.Read(lockFile)
+	if err != nil {
+		return err
+	}
+	if len(lf.Repos) == 0 {
+		return fmt.Errorf("%s lists no repositories", lockFile)
+	}
+	if err := a.initMonorepo(); err != nil {
+		return err
+	}
+	if a.plan != nil {
+		for _, method := range []string{types.MethodSubtree, types.MethodSubmodule} {
+			a.plan.Add(lockedRepos(lf.Repos, method), method == types.MethodSubtree)
+		}
+		a.plan.Write(lock.FileName, "store "+lockFile)
+		return nil
+	}
+
+	for _, method := range []string{types.MethodSubtree, types.MethodSubmodule} {
+		repos := lockedRepos(lf.Repos, method)
+		if len(repos) == 0 {
+			continue
+		}
+		if _, err := git.AddRepos(a.dir, withCloneURLs(repos, a.providers), method == types.MethodSubtree); err != nil {
+			return err
+		}
+	}
+
+	if err := lock.Save(a.dir, lf); err != nil {
+		return err
+	}
+	return git.Commit(a.dir, "Update "+lock.FileName, lock.FileName)
+}
+
+// scanLocal scans baseDir for local repositories, prints them and saves the
+// results to output.
+//
+// Parameters:
+//   - baseDir: Root directory to scan
+//   - monorepoPath: Path to the monorepo, whose repositories are marked as such
+//   - opts: The ignore patterns, depth limit, nested detection and concurrency of the scan
+//   - output: File the scan results are saved to
+//
+// Returns:
+//   - []local.LocalRepo: The repositories and directories found
+//   - error: Any error that occurred while scanning or saving
+func (a *app) scanLocal(baseDir string, monorepoPath string, opts types.ScanConfig, output string) ([]local.LocalRepo, error) {
+	fmt.Fprintln(a.out, "Scanning local repositories...")
+	if baseDir == "" || monorepoPath == "" {
+		return nil, fmt.Errorf("'base_dir' and 'monorepo_path' must be set in config.json or with --base-dir and --monorepo-path")
+	}
+
+	localRepos, err := local.SearchRepos(baseDir, monorepoPath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	local.PrintRepos(a.out, localRepos)
+	if err := local.SaveReposData(localRepos, output); err != nil {
+		return nil, fmt.Errorf("error saving local repository data: %w", err)
+	}
+	fmt.Fprintf(a.out, "Local repository data saved to %s\n", output)
+	return localRepos, nil
+}
+
+// matchLocal matches the local repositories with the cached or fetched
+// repositories of the providers and prints which are local-only, orphaned
+// or never cloned. Clones are matched by the URLs git uses, which differ
+// from the listed ones for providers with a separate git_host.
+func (a *app) matchLocal(localRepos []local.LocalRepo) error {
+	repos, err := a.getRepositories(context.Background())
+	if err != nil {
+		return err
+	}
+	inventory.PrintReport(a.out, inventory.Reconcile(localRepos, withCloneURLs(repos, a.providers)))
+	return nil
+}
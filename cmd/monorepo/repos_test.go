@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"christopherharwell/project_monorepo/pkg/cache"
+	"christopherharwell/project_monorepo/pkg/local"
+	"christopherharwell/project_monorepo/pkg/lock"
+	"christopherharwell/project_monorepo/pkg/manifest"
+	"christopherharwell/project_monorepo/pkg/plan"
+	"christopherharwell/project_monorepo/pkg/provider"
+	"christopherharwell/project_monorepo/pkg/types"
+)
+
+// fakeProvider returns a fixed list of repositories or a fixed error, and
+// clones from gitHost instead of the listed host when it is set.
+type fakeProvider struct {
+	name    string
+	repos   []types.Repo
+	err     error
+	gitHost string
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) ListRepos(ctx context.Context) ([]types.Repo, error) {
+	return p.repos, p.err
+}
+
+func (p *fakeProvider) CloneURL(r types.Repo) string {
+	if p.gitHost == "" {
+		return r.SSHURL
+	}
+	_, repoPath, _ := strings.Cut(r.SSHURL, ":")
+	return "git@" + p.gitHost + ":" + repoPath
+}
+
+func (p *fakeProvider) AuthMethod() provider.AuthMethod { return provider.AuthSSH }
+
+func instanceNames(providers []provider.Provider) []string {
+	var names []string
+	for _, p := range providers {
+		names = append(names, p.Name())
+	}
+	return names
+}
+
+func TestSelectLocked(t *testing.T) {
+	lf := lock.File{Repos: []lock.Entry{
+		{Name: "api", Path: "api"},
+		{Name: "api", Path: "acme-api"},
+		{Name: "Web", Path: "frontend/web"},
+	}}
+	tests := []struct {
+		queries []string
+		want    []string
+		err     string
+	}{
+		{queries: nil, want: []string{"api", "acme-api", "frontend/web"}},
+		{queries: []string{"api"}, want: []string{"api", "acme-api"}},
+		{queries: []string{"acme-api"}, want: []string{"acme-api"}},
+		{queries: []string{"web"}, want: []string{"frontend/web"}},
+		{queries: []string{"FRONTEND/WEB", "api", "acme-api"}, want: []string{"frontend/web", "api", "acme-api"}},
+		{queries: []string{"api", "docs"}, err: `no repository "docs"`},
+	}
+	for _, tt := range tests {
+		entries, err := selectLocked(lf, tt.queries)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("selectLocked(%q) = %v, want an error containing %q", tt.queries, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("selectLocked(%q): %v", tt.queries, err)
+			continue
+		}
+		var paths []string
+		for _, e := range entries {
+			paths = append(paths, e.Path)
+		}
+		if !slices.Equal(paths, tt.want) {
+			t.Errorf("selectLocked(%q) = %q, want %q", tt.queries, paths, tt.want)
+		}
+	}
+
+	if _, err := selectLocked(lock.File{}, nil); err == nil {
+		t.Error("selectLocked accepted an empty lockfile")
+	}
+}
+
+func TestStaleProviders(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	a := &app{
+		providers: []provider.Provider{
+			&fakeProvider{name: "fresh"},
+			&fakeProvider{name: "expired"},
+			&fakeProvider{name: "switched"},
+			&fakeProvider{name: "new"},
+		},
+		cacheKeys: map[string]string{"fresh": "github:dev", "expired": "gitlab:dev", "switched": "github:other", "new": "git:"},
+	}
+	f := cache.File{}
+	f.Set("fresh", "github:dev", nil, now.Add(-time.Minute))
+	f.Set("expired", "gitlab:dev", nil, now.Add(-2*time.Hour))
+	f.Set("switched", "github:dev", nil, now.Add(-time.Minute))
+
+	tests := []struct {
+		refresh refreshOptions
+		ttl     time.Duration
+		want    []string
+	}{
+		{ttl: time.Hour, want: []string{"expired", "switched", "new"}},
+		{ttl: time.Hour, refresh: refreshOptions{all: true}, want: []string{"fresh", "expired", "switched", "new"}},
+		{ttl: time.Hour, refresh: refreshOptions{providers: []string{"fresh"}}, want: []string{"fresh", "expired", "switched", "new"}},
+		{ttl: 3 * time.Hour, want: []string{"switched", "new"}},
+		{ttl: 0, want: []string{"switched", "new"}},
+	}
+	for _, tt := range tests {
+		a.refresh = tt.refresh
+		a.cacheTTL = tt.ttl
+		if got := instanceNames(a.staleProviders(f, now)); !slices.Equal(got, tt.want) {
+			t.Errorf("staleProviders(refresh %+v, ttl %s) = %q, want %q", tt.refresh, a.cacheTTL, got, tt.want)
+		}
+	}
+}
+
+func TestCheckRefresh(t *testing.T) {
+	tests := []struct {
+		providers []provider.Provider
+		refresh   []string
+		wantErr   bool
+	}{
+		{providers: []provider.Provider{&fakeProvider{name: "github"}}, refresh: []string{"github"}},
+		{providers: []provider.Provider{&fakeProvider{name: "github"}}, refresh: []string{"github", "typo"}, wantErr: true},
+		{refresh: nil},
+		{refresh: []string{"typo"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		a := &app{providers: tt.providers, refresh: refreshOptions{providers: tt.refresh}}
+		if err := a.checkRefresh(); (err != nil) != tt.wantErr {
+			t.Errorf("checkRefresh(%q with %q) = %v, want error %v", tt.refresh, instanceNames(tt.providers), err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadRepositoriesWithoutProviders(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	a := &app{refresh: refreshOptions{all: true, providers: []string{"typo"}}, out: io.Discard}
+	if _, err := a.loadRepositories(context.Background()); err == nil {
+		t.Error("Expected an unknown provider to be rejected without providers configured")
+	}
+
+	a.refresh = refreshOptions{all: true}
+	repos, err := a.loadRepositories(context.Background())
+	if err != nil || len(repos) != 0 {
+		t.Errorf("loadRepositories() = %v, %v, want no repositories", repos, err)
+	}
+	if _, err := os.Stat(cacheFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected no %s without providers, got %v", cacheFile, err)
+	}
+}
+
+func TestRefreshCache(t *testing.T) {
+	t.Chdir(t.TempDir())
+	now := time.Now()
+
+	github := &fakeProvider{name: "github", repos: []types.Repo{{Name: "api", SSHURL: "git@github.com:acme/api.git"}}}
+	gitlab := &fakeProvider{name: "gitlab", err: errors.New("unauthorized")}
+	var out bytes.Buffer
+	a := &app{
+		providers: []provider.Provider{github, gitlab},
+		cacheKeys: map[string]string{"github": "github:acme", "gitlab": "gitlab:acme"},
+		cacheTTL:  time.Hour,
+		out:       &out,
+	}
+	newCache := func() cache.File {
+		f := cache.File{}
+		f.Set("", "", []types.Repo{{Name: "legacy"}}, time.Time{})
+		f.Set("removed", "git:", []types.Repo{{Name: "old"}}, now)
+		f.Set("gitlab", "gitlab:acme", []types.Repo{{Name: "web"}}, now.Add(-2*time.Hour))
+		return f
+	}
+
+	// A dry run fetches without saving
+	f := newCache()
+	dryRun := &app{providers: a.providers, cacheKeys: a.cacheKeys, plan: plan.New("fetch", "mono"), out: &out}
+	err := dryRun.refreshCache(context.Background(), &f, a.providers)
+	var fetchErr *fetchError
+	if !errors.As(err, &fetchErr) || !strings.Contains(err.Error(), "unauthorized") {
+		t.Errorf("Expected the gitlab failure as *fetchError, got %v", err)
+	}
+	if _, err := os.Stat(cacheFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("A dry run wrote %s: %v", cacheFile, err)
+	}
+	if strings.Contains(out.String(), "Cached") {
+		t.Errorf("A dry run reported the cache as saved:\n%s", out.String())
+	}
+
+	out.Reset()
+	f = newCache()
+	err = a.refreshCache(context.Background(), &f, a.providers)
+	if !errors.As(err, &fetchErr) {
+		t.Errorf("Expected the gitlab failure as *fetchError, got %v", err)
+	}
+	if !strings.Contains(out.String(), "Cached 2 repositories in "+cacheFile) {
+		t.Errorf("Expected the saved cache to be reported:\n%s", out.String())
+	}
+
+	saved, err := cache.Load(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(saved.Providers))
+	for name := range saved.Providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"github", "gitlab"}) {
+		t.Errorf("Saved listings of %q, want only the configured github and gitlab", names)
+	}
+	if !saved.Fresh("github", "github:acme", a.cacheTTL, now) {
+		t.Error("Expected the github listing to be fresh")
+	}
+	if saved.Fresh("gitlab", "gitlab:acme", a.cacheTTL, now) {
+		t.Error("Expected the failed gitlab listing to stay expired")
+	}
+	if got := saved.Repos([]string{"github", "gitlab"}); len(got) != 2 || got[0].Name != "api" || got[0].Provider != "github" || got[1].Name != "web" {
+		t.Errorf("Unexpected cached repositories %+v", got)
+	}
+}
+
+func TestMatchLocalUsesCloneURLs(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	ghe := &fakeProvider{
+		name:    "ghe",
+		repos:   []types.Repo{{Name: "api", FullPath: "acme/api", SSHURL: "git@github.example.com:acme/api.git"}},
+		gitHost: "git.example.com",
+	}
+	var out bytes.Buffer
+	a := &app{providers: []provider.Provider{ghe}, cacheKeys: map[string]string{}, out: &out}
+	locals := []local.LocalRepo{
+		{Path: "/work/api", IsGitRepo: true, Remotes: []local.Remote{{Name: "origin", URL: "git@git.example.com:acme/api.git"}}},
+	}
+
+	if err := a.matchLocal(locals); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.Contains(got, "matched to fetched repositories: 1") || !strings.Contains(got, "never cloned: 0") {
+		t.Errorf("Expected the clone from the git host to be matched:\n%s", got)
+	}
+}
+
+func TestAddRecordsManifestMembers(t *testing.T) {
+	t.Chdir(t.TempDir())
+	setGitIdentity(t)
+	remotes := t.TempDir()
+	api := newBareRepo(t, remotes, "api")
+	web := newBareRepo(t, remotes, "web")
+	config := `{"remotes": [{"url": "` + api + `"}, {"url": "` + web + `"}]}`
+	if err := os.WriteFile(defaultConfigFile, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	members := func() map[string]string {
+		t.Helper()
+		m, err := manifest.Load("mono")
+		if err != nil {
+			t.Fatal(err)
+		}
+		methods := map[string]string{}
+		for _, member := range m.Members {
+			methods[member.Path] = member.Method
+		}
+		return methods
+	}
+
+	steps := []struct {
+		args []string
+		want map[string]string
+	}{
+		{args: []string{"init", "--monorepo", "mono"}},
+		{args: []string{"add", "--monorepo", "mono", "--write-manifest", "--subtree", "api"}, want: map[string]string{"api": types.MethodSubtree}},
+		{args: []string{"add", "--monorepo", "mono", "--from-manifest"}, want: map[string]string{"api": types.MethodSubtree}},
+		// A plain add lists the new repository in the existing manifest
+		{args: []string{"add", "--monorepo", "mono", "--subtree", "web"}, want: map[string]string{"api": types.MethodSubtree, "web": types.MethodSubtree}},
+		{args: []string{"status", "--monorepo", "mono"}, want: map[string]string{"api": types.MethodSubtree, "web": types.MethodSubtree}},
+		// Writing the manifest keeps the members that are not selected
+		{args: []string{"add", "--monorepo", "mono", "--write-manifest", "--subtree=false", "web"}, want: map[string]string{"api": types.MethodSubtree, "web": types.MethodSubmodule}},
+	}
+	for _, step := range steps {
+		var code int
+		stdout, stderr := captureOutput(t, func() { code = run(step.args) })
+		if code != exitOK {
+			t.Fatalf("run(%q) = %d, want %d\nstdout: %s\nstderr: %s", step.args, code, exitOK, stdout, stderr)
+		}
+		if step.want == nil {
+			continue
+		}
+		if got := members(); !maps.Equal(got, step.want) {
+			t.Errorf("After %q the manifest lists %v, want %v", step.args, got, step.want)
+		}
+	}
+}
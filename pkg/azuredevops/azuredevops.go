@@ -0,0 +1,195 @@
+// Package azuredevops implements a provider for Azure DevOps Repos.
+package azuredevops
+
+import (
+	"christopherharwell/project_monorepo/pkg/httputil"
+	"christopherharwell/project_monorepo/pkg/provider"
+	"christopherharwell/project_monorepo/pkg/types"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	defaultAPIURL = "https://dev.azure.com"
+	apiVersion    = "7.1"
+)
+
+func init() {
+	provider.Register("azuredevops", NewProvider)
+}
+
+// azureProvider lists the Git repositories of every project in an Azure
+// DevOps organization.
+type azureProvider struct {
+	name     string
+	orgURL   string
+	token    string
+	maxPages int
+	client   *httputil.Client
+}
+
+// listResponse is the envelope Azure DevOps wraps around collections.
+type listResponse[T any] struct {
+	Count int `json:"count"`
+	Value []T `json:"value"`
+}
+
+// project is the subset of the Azure DevOps team project model used here.
+type project struct {
+	Name string `json:"name"`
+}
+
+// repository is the subset of the Azure DevOps Git repository model used here.
+type repository struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	DefaultBranch string `json:"defaultBranch"`
+	SSHURL        string `json:"sshUrl"`
+	RemoteURL     string `json:"remoteUrl"`
+	WebURL        string `json:"webUrl"`
+	IsDisabled    bool   `json:"isDisabled"`
+	Project       struct {
+		Name       string `json:"name"`
+		Visibility string `json:"visibility"`
+	} `json:"project"`
+}
+
+// NewProvider creates an Azure DevOps provider for cfg.Organization
+// authenticating with the personal access token in cfg.Token. cfg.APIURL
+// overrides https://dev.azure.com, e.g. for Azure DevOps Server collections.
+func NewProvider(cfg types.ProviderConfig) (provider.Provider, error) {
+	name := provider.InstanceName(cfg)
+	if cfg.Organization == "" {
+		return nil, fmt.Errorf("provider %q: organization is required for Azure DevOps", name)
+	}
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("provider %q: Azure DevOps personal access token is empty", name)
+	}
+
+	apiURL := defaultAPIURL
+	if cfg.APIURL != "" {
+		apiURL = strings.TrimSuffix(cfg.APIURL, "/")
+	}
+	return &azureProvider{
+		name:     name,
+		orgURL:   apiURL + "/" + url.PathEscape(cfg.Organization),
+		token:    cfg.Token,
+		maxPages: cfg.MaxPages,
+		client:   httputil.NewClient("Azure DevOps", nil),
+	}, nil
+}
+
+func (p *azureProvider) Name() string {
+	return p.name
+}
+
+// ListRepos enumerates the projects of the organization and returns the
+// enabled Git repositories of each one.
+func (p *azureProvider) ListRepos(ctx context.Context) ([]types.Repo, error) {
+	projects, err := p.fetchProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []types.Repo
+	for _, proj := range projects {
+		var data listResponse[repository]
+		reposURL := fmt.Sprintf("%s/%s/_apis/git/repositories?api-version=%s", p.orgURL, url.PathEscape(proj.Name), apiVersion)
+		if _, err := p.getJSON(ctx, reposURL, &data); err != nil {
+			return nil, err
+		}
+
+		for _, r := range data.Value {
+			if r.IsDisabled {
+				continue
+			}
+			repos = append(repos, types.Repo{
+				Name:          r.Name,
+				ID:            r.ID,
+				SSHURL:        r.SSHURL,
+				DefaultBranch: strings.TrimPrefix(r.DefaultBranch, "refs/heads/"),
+				Owner:         r.Project.Name,
+				FullPath:      r.Project.Name + "/" + r.Name,
+				HTTPSURL:      r.RemoteURL,
+				WebURL:        r.WebURL,
+				Visibility:    r.Project.Visibility,
+			})
+		}
+	}
+	return repos, nil
+}
+
+// CloneURL returns the SSH URL of r; Azure DevOps repositories are cloned over SSH.
+func (p *azureProvider) CloneURL(r types.Repo) string {
+	return r.SSHURL
+}
+
+func (p *azureProvider) AuthMethod() provider.AuthMethod {
+	return provider.AuthSSH
+}
+
+// fetchProjects lists every project of the organization, following the
+// x-ms-continuationtoken header until the last page or the page limit.
+func (p *azureProvider) fetchProjects(ctx context.Context) ([]project, error) {
+	var projects []project
+	pages := 0
+	continuation := ""
+	for {
+		if p.maxPages > 0 && pages == p.maxPages {
+			fmt.Fprintf(os.Stderr, "Warning: %s project listing stopped at the %d page limit\n", p.name, pages)
+			break
+		}
+
+		query := url.Values{"api-version": {apiVersion}, "$top": {"100"}}
+		if continuation != "" {
+			query.Set("continuationToken", continuation)
+		}
+
+		var data listResponse[project]
+		var err error
+		continuation, err = p.getJSON(ctx, p.orgURL+"/_apis/projects?"+query.Encode(), &data)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, data.Value...)
+		pages++
+
+		if continuation == "" {
+			break
+		}
+	}
+	return projects, nil
+}
+
+// getJSON decodes the response of a GET request for url into v and returns
+// the continuation token of the response, if any.
+func (p *azureProvider) getJSON(ctx context.Context, url string, v interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	// Personal access tokens are sent as the password of basic auth with an empty user
+	req.SetBasicAuth("", p.token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// Invalid tokens are redirected to a sign-in page instead of returning 401
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		return "", httputil.NewAPIError(p.client.Service, resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", fmt.Errorf("error decoding Azure DevOps response (URL: %s): %w", url, err)
+	}
+	return resp.Header.Get("x-ms-continuationtoken"), nil
+}
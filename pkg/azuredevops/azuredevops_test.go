@@ -0,0 +1,111 @@
+package azuredevops
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"christopherharwell/project_monorepo/pkg/types"
+)
+
+// newFixtureServer replays the responses recorded in testdata for the
+// "contoso" organization. The project listing is split over two pages
+// linked by a continuation token.
+func newFixtureServer(t *testing.T) *httptest.Server {
+	serve := func(w http.ResponseWriter, fixture string) {
+		data, err := os.ReadFile(filepath.Join("testdata", fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8; api-version=7.1")
+		w.Write(data)
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, password, ok := r.BasicAuth(); !ok || password != "test-pat" {
+			// Azure DevOps answers invalid tokens with a sign-in page
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusNonAuthoritativeInfo)
+			w.Write([]byte("<html>Sign In</html>"))
+			return
+		}
+		if r.URL.Query().Get("api-version") != apiVersion {
+			t.Errorf("Expected api-version=%s, got %q", apiVersion, r.URL.RawQuery)
+		}
+
+		switch r.URL.Path {
+		case "/contoso/_apis/projects":
+			if r.URL.Query().Get("continuationToken") == "page2" {
+				serve(w, "projects_2.json")
+				return
+			}
+			w.Header().Set("x-ms-continuationtoken", "page2")
+			serve(w, "projects_1.json")
+		case "/contoso/Client Portal/_apis/git/repositories":
+			serve(w, "repositories_client_portal.json")
+		case "/contoso/Infrastructure/_apis/git/repositories":
+			serve(w, "repositories_infrastructure.json")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestListRepos(t *testing.T) {
+	ts := newFixtureServer(t)
+	defer ts.Close()
+
+	p, err := NewProvider(types.ProviderConfig{Type: "azuredevops", Organization: "contoso", Token: "test-pat", APIURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repos, err := p.ListRepos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []types.Repo{
+		{Name: "portal-web", SSHURL: "git@ssh.dev.azure.com:v3/contoso/Client%20Portal/portal-web", DefaultBranch: "main"},
+		{Name: "portal-api", SSHURL: "git@ssh.dev.azure.com:v3/contoso/Client%20Portal/portal-api", DefaultBranch: "develop"},
+		{Name: "terraform", SSHURL: "git@ssh.dev.azure.com:v3/contoso/Infrastructure/terraform"},
+	}
+	if len(repos) != len(want) {
+		t.Fatalf("Expected %d repos, got %d: %+v", len(want), len(repos), repos)
+	}
+	for i := range want {
+		if repos[i].Name != want[i].Name || repos[i].SSHURL != want[i].SSHURL || repos[i].DefaultBranch != want[i].DefaultBranch {
+			t.Errorf("Repo %d: expected %+v, got %+v", i, want[i], repos[i])
+		}
+	}
+
+	web := repos[0]
+	if web.Owner != "Client Portal" || web.FullPath != "Client Portal/portal-web" || web.Visibility != "private" {
+		t.Errorf("Unexpected metadata: %+v", web)
+	}
+	if web.WebURL != "https://dev.azure.com/contoso/Client%20Portal/_git/portal-web" || web.HTTPSURL != "https://contoso@dev.azure.com/contoso/Client%20Portal/_git/portal-web" {
+		t.Errorf("Unexpected URLs: %+v", web)
+	}
+}
+
+func TestListReposInvalidToken(t *testing.T) {
+	ts := newFixtureServer(t)
+	defer ts.Close()
+
+	p, err := NewProvider(types.ProviderConfig{Type: "azuredevops", Organization: "contoso", Token: "expired", APIURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ListRepos(context.Background()); err == nil {
+		t.Error("Expected an error for an invalid token")
+	}
+}
+
+func TestNewProviderRequiresOrganization(t *testing.T) {
+	if _, err := NewProvider(types.ProviderConfig{Type: "azuredevops", Token: "test-pat"}); err == nil {
+		t.Error("Expected an error without an organization")
+	}
+}
@@ -0,0 +1,208 @@
+// Package bitbucket implements a provider for Bitbucket Cloud workspaces.
+package bitbucket
+
+import (
+	"christopherharwell/project_monorepo/pkg/httputil"
+	"christopherharwell/project_monorepo/pkg/provider"
+	"christopherharwell/project_monorepo/pkg/types"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultAPIURL = "https://api.bitbucket.org/2.0"
+
+func init() {
+	provider.Register("bitbucket", NewProvider)
+}
+
+// bitbucketProvider lists the repositories of every workspace a Bitbucket
+// Cloud user has access to.
+type bitbucketProvider struct {
+	name        string
+	apiURL      string
+	username    string
+	appPassword string
+	maxPages    int
+	client      *httputil.Client
+}
+
+// page is the envelope Bitbucket wraps around paginated listings.
+type page[T any] struct {
+	Values []T    `json:"values"`
+	Next   string `json:"next"`
+}
+
+// workspaceAccess is an entry of the /user/permissions/workspaces listing.
+type workspaceAccess struct {
+	Workspace struct {
+		Slug string `json:"slug"`
+	} `json:"workspace"`
+}
+
+// repository is the subset of the Bitbucket repository model used here.
+type repository struct {
+	UUID        string    `json:"uuid"`
+	Slug        string    `json:"slug"`
+	FullName    string    `json:"full_name"`
+	Description string    `json:"description"`
+	Language    string    `json:"language"`
+	IsPrivate   bool      `json:"is_private"`
+	CreatedOn   time.Time `json:"created_on"`
+	UpdatedOn   time.Time `json:"updated_on"`
+	Parent      *struct{} `json:"parent"`
+	MainBranch  *struct {
+		Name string `json:"name"`
+	} `json:"mainbranch"`
+	Workspace struct {
+		Slug string `json:"slug"`
+	} `json:"workspace"`
+	Links struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+		Clone []struct {
+			Name string `json:"name"`
+			Href string `json:"href"`
+		} `json:"clone"`
+	} `json:"links"`
+}
+
+// NewProvider creates a Bitbucket Cloud provider authenticating with an app
+// password: cfg.Username is the Bitbucket username and cfg.Token the app password.
+func NewProvider(cfg types.ProviderConfig) (provider.Provider, error) {
+	name := provider.InstanceName(cfg)
+	if cfg.Username == "" || cfg.Token == "" {
+		return nil, fmt.Errorf("provider %q: Bitbucket requires a username and an app password token", name)
+	}
+
+	apiURL := defaultAPIURL
+	if cfg.APIURL != "" {
+		apiURL = strings.TrimSuffix(cfg.APIURL, "/")
+	}
+	return &bitbucketProvider{
+		name:        name,
+		apiURL:      apiURL,
+		username:    cfg.Username,
+		appPassword: cfg.Token,
+		maxPages:    cfg.MaxPages,
+		client:      httputil.NewClient("Bitbucket", nil),
+	}, nil
+}
+
+func (p *bitbucketProvider) Name() string {
+	return p.name
+}
+
+// ListRepos returns the repositories of every workspace the user belongs to.
+// Repositories are named by their slug, which is safe to use as a directory name.
+func (p *bitbucketProvider) ListRepos(ctx context.Context) ([]types.Repo, error) {
+	workspaces, err := fetchAll[workspaceAccess](ctx, p, p.apiURL+"/user/permissions/workspaces?pagelen=100")
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []types.Repo
+	for _, w := range workspaces {
+		slug := w.Workspace.Slug
+		if slug == "" {
+			continue
+		}
+
+		data, err := fetchAll[repository](ctx, p, fmt.Sprintf("%s/repositories/%s?pagelen=100", p.apiURL, url.PathEscape(slug)))
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range data {
+			repos = append(repos, toRepo(r))
+		}
+	}
+	return repos, nil
+}
+
+// CloneURL returns the SSH URL of r; Bitbucket repositories are cloned over SSH.
+func (p *bitbucketProvider) CloneURL(r types.Repo) string {
+	return r.SSHURL
+}
+
+func (p *bitbucketProvider) AuthMethod() provider.AuthMethod {
+	return provider.AuthSSH
+}
+
+func toRepo(r repository) types.Repo {
+	repo := types.Repo{
+		Name:        r.Slug,
+		ID:          r.UUID,
+		Owner:       r.Workspace.Slug,
+		FullPath:    r.FullName,
+		WebURL:      r.Links.HTML.Href,
+		Description: r.Description,
+		Language:    r.Language,
+		Visibility:  "public",
+		Fork:        r.Parent != nil,
+		CreatedAt:   r.CreatedOn,
+		PushedAt:    r.UpdatedOn,
+	}
+	if r.IsPrivate {
+		repo.Visibility = "private"
+	}
+	if r.MainBranch != nil {
+		repo.DefaultBranch = r.MainBranch.Name
+	}
+	for _, link := range r.Links.Clone {
+		switch link.Name {
+		case "ssh":
+			repo.SSHURL = link.Href
+		case "https":
+			repo.HTTPSURL = link.Href
+		}
+	}
+	return repo
+}
+
+// fetchAll requests every page of a listing, following the next field of
+// each page until the last page or the page limit is reached.
+func fetchAll[T any](ctx context.Context, p *bitbucketProvider, next string) ([]T, error) {
+	var items []T
+	pages := 0
+	for next != "" {
+		if p.maxPages > 0 && pages == p.maxPages {
+			fmt.Fprintf(os.Stderr, "Warning: %s listing stopped at the %d page limit\n", p.name, pages)
+			break
+		}
+
+		var current page[T]
+		if err := p.getJSON(ctx, next, &current); err != nil {
+			return nil, err
+		}
+		items = append(items, current.Values...)
+		pages++
+		next = current.Next
+	}
+	return items, nil
+}
+
+func (p *bitbucketProvider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(p.username, p.appPassword)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error decoding Bitbucket response (URL: %s): %w", url, err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package bitbucket
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"christopherharwell/project_monorepo/pkg/types"
+)
+
+func repoJSON(workspace, slug, branch string) string {
+	return fmt.Sprintf(`{
+		"name": "%s display name",
+		"slug": "%s",
+		"full_name": "%s/%s",
+		"is_private": true,
+		"language": "python",
+		"updated_on": "2024-06-01T10:00:00.000000+00:00",
+		"workspace": {"slug": "%s"},
+		"mainbranch": {"type": "branch", "name": "%s"},
+		"links": {"clone": [
+			{"name": "https", "href": "https://bitbucket.org/%s/%s.git"},
+			{"name": "ssh", "href": "git@bitbucket.org:%s/%s.git"}
+		]}
+	}`, slug, slug, workspace, slug, workspace, branch, workspace, slug, workspace, slug)
+}
+
+func TestListRepos(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "me" || password != "app-password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/user/permissions/workspaces":
+			fmt.Fprint(w, `{"values": [
+				{"permission": "owner", "workspace": {"slug": "me"}},
+				{"permission": "member", "workspace": {"slug": "legacy-team"}}
+			]}`)
+		case "/repositories/me":
+			fmt.Fprintf(w, `{"values": [%s]}`, repoJSON("me", "scripts", "master"))
+		case "/repositories/legacy-team":
+			if r.URL.Query().Get("page") == "2" {
+				fmt.Fprintf(w, `{"values": [%s, {"slug": "empty", "mainbranch": null, "links": {"clone": []}}]}`, repoJSON("legacy-team", "api", "develop"))
+				return
+			}
+			fmt.Fprintf(w, `{"values": [%s], "next": "%s/repositories/legacy-team?pagelen=100&page=2"}`, repoJSON("legacy-team", "web", "main"), ts.URL)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	p, err := NewProvider(types.ProviderConfig{Type: "bitbucket", Username: "me", Token: "app-password", APIURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repos, err := p.ListRepos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []types.Repo{
+		{Name: "scripts", SSHURL: "git@bitbucket.org:me/scripts.git", DefaultBranch: "master"},
+		{Name: "web", SSHURL: "git@bitbucket.org:legacy-team/web.git", DefaultBranch: "main"},
+		{Name: "api", SSHURL: "git@bitbucket.org:legacy-team/api.git", DefaultBranch: "develop"},
+		{Name: "empty"},
+	}
+	if len(repos) != len(want) {
+		t.Fatalf("Expected %d repos, got %d: %+v", len(want), len(repos), repos)
+	}
+	for i := range want {
+		if repos[i].Name != want[i].Name || repos[i].SSHURL != want[i].SSHURL || repos[i].DefaultBranch != want[i].DefaultBranch {
+			t.Errorf("Repo %d: expected %+v, got %+v", i, want[i], repos[i])
+		}
+	}
+
+	web := repos[1]
+	if web.Owner != "legacy-team" || web.FullPath != "legacy-team/web" || web.Visibility != "private" || web.Language != "python" {
+		t.Errorf("Unexpected metadata: %+v", web)
+	}
+	if web.HTTPSURL != "https://bitbucket.org/legacy-team/web.git" || web.PushedAt.Year() != 2024 {
+		t.Errorf("Unexpected metadata: %+v", web)
+	}
+}
+
+func TestListReposUnauthorized(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	p, err := NewProvider(types.ProviderConfig{Type: "bitbucket", Username: "me", Token: "wrong", APIURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ListRepos(context.Background()); err == nil {
+		t.Error("Expected an error for invalid credentials")
+	}
+}
+
+func TestNewProviderRequiresCredentials(t *testing.T) {
+	if _, err := NewProvider(types.ProviderConfig{Type: "bitbucket", Token: "app-password"}); err == nil {
+		t.Error("Expected an error without a username")
+	}
+}
@@ -0,0 +1,312 @@
+// Package cache stores the repository listings of the providers between runs,
+// one entry per provider instance with the time it was fetched, and reports
+// what changed in a listing when it is refreshed.
+package cache
+
+import (
+	"christopherharwell/project_monorepo/pkg/httputil"
+	"christopherharwell/project_monorepo/pkg/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Version is the cache format written by this package.
+const Version = 1
+
+// DefaultTTL is how long a listing stays fresh when the configuration sets no TTL.
+const DefaultTTL = 24 * time.Hour
+
+// File holds the cached listing of every provider instance.
+type File struct {
+	// Version is the format version of the cache file
+	Version int `json:"version"`
+
+	// Providers are the listings keyed by provider instance name
+	Providers map[string]Entry `json:"providers"`
+}
+
+// Entry is the cached listing of one provider instance.
+type Entry struct {
+	// Key identifies the provider type and account the listing was fetched
+	// with; a listing whose key no longer matches the configuration is stale
+	Key string `json:"key"`
+
+	// FetchedAt is when the listing was fetched
+	FetchedAt time.Time `json:"fetched_at"`
+
+	// Repos are the repositories the provider listed
+	Repos []types.Repo `json:"repos"`
+
+	// Pages are the API responses of the listing keyed by request URL, kept
+	// by providers that revalidate them with conditional requests
+	Pages map[string]httputil.Page `json:"pages,omitempty"`
+}
+
+// Load reads the cache from cacheFile. A missing or empty cache file is not
+// an error and yields an empty cache. Caches written before listings were
+// kept per provider are converted, with every listing stale.
+//
+// Parameters:
+//   - cacheFile: Path to the JSON cache file
+//
+// Returns:
+//   - File: The cached listings
+//   - error: Any error that occurred during file reading or JSON parsing
+func Load(cacheFile string) (File, error) {
+	f := File{Version: Version, Providers: map[string]Entry{}}
+	data, err := os.ReadFile(cacheFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return File{}, err
+	}
+	data = []byte(strings.TrimSpace(string(data)))
+	if len(data) == 0 {
+		return f, nil
+	}
+
+	if data[0] == '[' {
+		var repos []types.Repo
+		if err := json.Unmarshal(data, &repos); err != nil {
+			return File{}, fmt.Errorf("error parsing %s: %w", cacheFile, err)
+		}
+		for _, r := range repos {
+			e := f.Providers[r.Provider]
+			e.Repos = append(e.Repos, r)
+			f.Providers[r.Provider] = e
+		}
+		return f, nil
+	}
+
+	if err := json.Unmarshal(data, &f); err != nil {
+		return File{}, fmt.Errorf("error parsing %s: %w", cacheFile, err)
+	}
+	if f.Version < 1 || f.Version > Version {
+		return File{}, fmt.Errorf("%s: unsupported version %d", cacheFile, f.Version)
+	}
+	if f.Providers == nil {
+		f.Providers = map[string]Entry{}
+	}
+	return f, nil
+}
+
+// Save writes f to cacheFile as indented JSON.
+func Save(cacheFile string, f File) error {
+	f.Version = Version
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cacheFile, append(data, '\n'), 0644)
+}
+
+// Fresh reports whether the listing of provider name was fetched with key
+// less than ttl before now. A ttl of zero never expires a listing.
+func (f File) Fresh(name string, key string, ttl time.Duration, now time.Time) bool {
+	e, ok := f.Providers[name]
+	if !ok || e.Key != key || e.FetchedAt.IsZero() {
+		return false
+	}
+	return ttl == 0 || now.Sub(e.FetchedAt) < ttl
+}
+
+// Set replaces the listing of provider name.
+func (f *File) Set(name string, key string, repos []types.Repo, at time.Time) {
+	if f.Providers == nil {
+		f.Providers = map[string]Entry{}
+	}
+	f.Providers[name] = Entry{Key: key, FetchedAt: at.UTC(), Repos: repos}
+}
+
+// SetPages keeps the API responses of the listing of provider name, which
+// must have been set before.
+func (f *File) SetPages(name string, pages map[string]httputil.Page) {
+	e, ok := f.Providers[name]
+	if !ok {
+		return
+	}
+	e.Pages = pages
+	f.Providers[name] = e
+}
+
+// Retain drops the listings of providers other than names, such as providers
+// removed from the configuration or the unnamed listing of a converted cache.
+func (f *File) Retain(names []string) {
+	keep := map[string]bool{}
+	for _, name := range names {
+		keep[name] = true
+	}
+	for name := range f.Providers {
+		if !keep[name] {
+			delete(f.Providers, name)
+		}
+	}
+}
+
+// Repos returns the cached repositories of the named providers, in the
+// order of names. Providers without a listing contribute nothing.
+func (f File) Repos(names []string) []types.Repo {
+	var repos []types.Repo
+	for _, name := range names {
+		repos = append(repos, f.Providers[name].Repos...)
+	}
+	return repos
+}
+
+// Change kinds reported by Diff.
+const (
+	// ChangeNew is a repository that was not listed before
+	ChangeNew = "new"
+
+	// ChangeDeleted is a repository that is no longer listed
+	ChangeDeleted = "deleted"
+
+	// ChangeRenamed is a repository listed under a different path than before
+	ChangeRenamed = "renamed"
+
+	// ChangeBranch is a repository whose default branch changed
+	ChangeBranch = "default-branch"
+)
+
+// Change describes how a repository differs between two listings.
+type Change struct {
+	// Kind is one of the Change constants
+	Kind string
+
+	// Old is the repository in the previous listing; empty for new repositories
+	Old types.Repo
+
+	// New is the repository in the current listing; empty for deleted repositories
+	New types.Repo
+}
+
+// String describes the change for display.
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeNew:
+		return fmt.Sprintf("new      %s", displayName(c.New))
+	case ChangeDeleted:
+		return fmt.Sprintf("deleted  %s", displayName(c.Old))
+	case ChangeRenamed:
+		return fmt.Sprintf("renamed  %s -> %s", displayName(c.Old), displayName(c.New))
+	}
+	return fmt.Sprintf("branch   %s: %s -> %s", displayName(c.New), c.Old.DefaultBranch, c.New.DefaultBranch)
+}
+
+// Diff compares two listings of the same provider. Repositories are
+// matched by their provider ID when both listings have one, and otherwise by
+// full path or clone URL; a deleted and a new repository created at the same
+// time are taken to be one renamed repository.
+//
+// Parameters:
+//   - old: The previous listing
+//   - current: The new listing
+//
+// Returns:
+//   - []Change: The renamed, default-branch-changed, new and deleted repositories
+func Diff(old []types.Repo, current []types.Repo) []Change {
+	byID := map[string]int{}
+	byPath := map[string]int{}
+	for i, r := range old {
+		if r.ID != "" {
+			byID[r.ID] = i
+		}
+		byPath[identity(r)] = i
+	}
+
+	var changes []Change
+	matched := make([]bool, len(old))
+	var added []types.Repo
+	for _, r := range current {
+		i, ok := byID[r.ID]
+		if r.ID == "" || !ok {
+			i, ok = byPath[identity(r)]
+		}
+		if !ok || matched[i] {
+			added = append(added, r)
+			continue
+		}
+		matched[i] = true
+		changes = append(changes, compare(old[i], r)...)
+	}
+
+	var deleted []types.Repo
+	for i, r := range old {
+		if !matched[i] {
+			deleted = append(deleted, r)
+		}
+	}
+
+	// Without IDs, a rename shows up as a deletion and an addition of a
+	// repository with the same creation time
+	for _, r := range added {
+		j := -1
+		for k, d := range deleted {
+			if !r.CreatedAt.IsZero() && r.CreatedAt.Equal(d.CreatedAt) && (r.ID == "" || d.ID == "") {
+				j = k
+				break
+			}
+		}
+		if j < 0 {
+			changes = append(changes, Change{Kind: ChangeNew, New: r})
+			continue
+		}
+		changes = append(changes, compare(deleted[j], r)...)
+		deleted = append(deleted[:j], deleted[j+1:]...)
+	}
+	for _, r := range deleted {
+		changes = append(changes, Change{Kind: ChangeDeleted, Old: r})
+	}
+
+	order := map[string]int{ChangeRenamed: 0, ChangeBranch: 1, ChangeNew: 2, ChangeDeleted: 3}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return order[changes[i].Kind] < order[changes[j].Kind]
+	})
+	return changes
+}
+
+// compare returns the changes between two listings of the same repository.
+func compare(old types.Repo, current types.Repo) []Change {
+	var changes []Change
+	if identity(old) != identity(current) {
+		changes = append(changes, Change{Kind: ChangeRenamed, Old: old, New: current})
+	}
+	if old.DefaultBranch != current.DefaultBranch {
+		changes = append(changes, Change{Kind: ChangeBranch, Old: old, New: current})
+	}
+	return changes
+}
+
+// identity is the full path of r, or its clone URL when the provider reports none.
+func identity(r types.Repo) string {
+	if r.FullPath != "" {
+		return strings.ToLower(r.FullPath)
+	}
+	return r.SSHURL
+}
+
+func displayName(r types.Repo) string {
+	if r.FullPath != "" {
+		return r.FullPath
+	}
+	return r.Name
+}
+
+// PrintChanges prints the changes in the listing of provider name to w.
+func PrintChanges(w io.Writer, name string, changes []Change) {
+	if len(changes) == 0 {
+		fmt.Fprintf(w, "%s: no changes since the last fetch\n", name)
+		return
+	}
+	fmt.Fprintf(w, "%s: %d change(s) since the last fetch\n", name, len(changes))
+	for _, c := range changes {
+		fmt.Fprintf(w, "  %s\n", c)
+	}
+}
@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"christopherharwell/project_monorepo/pkg/httputil"
+	"christopherharwell/project_monorepo/pkg/types"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "repo_cache.json")
+
+	f, err := Load(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Providers) != 0 {
+		t.Fatalf("Expected empty cache, got %+v", f)
+	}
+
+	want := []types.Repo{{
+		Name:          "test-repo",
+		ID:            "42",
+		SSHURL:        "git@github.com:test/test-repo.git",
+		DefaultBranch: "main",
+		Provider:      "github",
+		Owner:         "test",
+		Topics:        []string{"go", "cli"},
+		Fork:          true,
+		Stars:         3,
+		PushedAt:      time.Date(2025, 4, 18, 19, 30, 3, 0, time.UTC),
+	}}
+	at := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	f.Set("github", "github:abc", want, at)
+	f.SetPages("github", map[string]httputil.Page{"https://api.github.com/user/repos": {ETag: `"abc"`, Body: []byte(`[]`)}})
+	if err := Save(cacheFile, f); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = Load(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := f.Providers["github"]; e.Key != "github:abc" || !e.FetchedAt.Equal(at) {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+	if page := f.Providers["github"].Pages["https://api.github.com/user/repos"]; page.ETag != `"abc"` || string(page.Body) != "[]" {
+		t.Errorf("Unexpected page %+v", page)
+	}
+	if repos := f.Repos([]string{"gitlab", "github"}); !reflect.DeepEqual(repos, want) {
+		t.Errorf("Expected %v, got %v", want, repos)
+	}
+}
+
+func TestLoadConvertsRepoList(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "repo_cache.json")
+	data := `[{"Name": "api", "Provider": "github"}, {"Name": "web", "Provider": "gitlab"}, {"Name": "cli", "Provider": "github"}]`
+	if err := os.WriteFile(cacheFile, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Load(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.Repos([]string{"github"}); len(got) != 2 || got[0].Name != "api" || got[1].Name != "cli" {
+		t.Errorf("Unexpected github listing %+v", got)
+	}
+	if f.Fresh("github", "", 0, time.Now()) {
+		t.Error("A converted listing must be stale")
+	}
+}
+
+func TestRetain(t *testing.T) {
+	f := File{Providers: map[string]Entry{
+		"":       {Repos: []types.Repo{{Name: "legacy"}}},
+		"github": {Key: "github:octocat", Repos: []types.Repo{{Name: "api"}}},
+		"old":    {Key: "gitlab:dev", Repos: []types.Repo{{Name: "web"}}},
+	}}
+	f.Retain([]string{"github", "remotes"})
+	if len(f.Providers) != 1 || f.Providers["github"].Key != "github:octocat" {
+		t.Errorf("Expected only the github listing to be kept, got %+v", f.Providers)
+	}
+}
+
+func TestFresh(t *testing.T) {
+	at := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	var f File
+	f.Set("github", "github:abc", nil, at)
+
+	tests := []struct {
+		name string
+		key  string
+		ttl  time.Duration
+		now  time.Time
+		want bool
+	}{
+		{"github", "github:abc", time.Hour, at.Add(30 * time.Minute), true},
+		{"github", "github:abc", time.Hour, at.Add(2 * time.Hour), false},
+		{"github", "github:abc", 0, at.Add(1000 * time.Hour), true},
+		{"github", "github:other", time.Hour, at, false},
+		{"gitlab", "gitlab:abc", time.Hour, at, false},
+	}
+	for _, tt := range tests {
+		if got := f.Fresh(tt.name, tt.key, tt.ttl, tt.now); got != tt.want {
+			t.Errorf("Fresh(%s, %s, %v, +%v) = %v, want %v", tt.name, tt.key, tt.ttl, tt.now.Sub(at), got, tt.want)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	old := []types.Repo{
+		{ID: "1", Name: "api", FullPath: "acme/api", DefaultBranch: "master"},
+		{ID: "2", Name: "web", FullPath: "acme/web", DefaultBranch: "main"},
+		{ID: "3", Name: "old", FullPath: "acme/old", DefaultBranch: "main"},
+		{Name: "notes", SSHURL: "nas:/git/notes.git", DefaultBranch: "main"},
+		{Name: "tools", FullPath: "acme/tools", DefaultBranch: "main", CreatedAt: created},
+	}
+	current := []types.Repo{
+		{ID: "1", Name: "api", FullPath: "acme/api", DefaultBranch: "main"},
+		{ID: "2", Name: "site", FullPath: "acme/site", DefaultBranch: "main"},
+		{ID: "4", Name: "new", FullPath: "acme/new", DefaultBranch: "main"},
+		{Name: "notes", SSHURL: "nas:/git/notes.git", DefaultBranch: "main"},
+		{Name: "toolkit", FullPath: "acme/toolkit", DefaultBranch: "main", CreatedAt: created},
+	}
+
+	var got []string
+	for _, c := range Diff(old, current) {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"renamed  acme/web -> acme/site",
+		"renamed  acme/tools -> acme/toolkit",
+		"branch   acme/api: master -> main",
+		"new      acme/new",
+		"deleted  acme/old",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff =\n%q\nwant\n%q", got, want)
+	}
+}
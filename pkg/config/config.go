@@ -1,9 +1,12 @@
 package config
 
 import (
+	"christopherharwell/project_monorepo/pkg/cache"
 	"christopherharwell/project_monorepo/pkg/types"
 	"encoding/json"
+	"fmt"
 	"os"
+	"time"
 )
 
 // LoadConfig reads and parses the configuration file.
@@ -33,4 +36,50 @@ func LoadConfig(configFile string) (types.Config, error) {
 		return types.Config{}, err
 	}
 	return cfg, nil
-} 
\ No newline at end of file
+}
+
+// Providers returns the provider configurations of cfg. When cfg.Providers is
+// empty, GitHub and GitLab providers are derived from the legacy token fields,
+// skipping the ones without a token. Remotes listed in cfg.Remotes are served
+// by an additional "git" provider named "remotes". Provider page limits
+// default to cfg.MaxPages.
+func Providers(cfg types.Config) []types.ProviderConfig {
+	providers := append([]types.ProviderConfig(nil), cfg.Providers...)
+	if len(providers) == 0 {
+		if cfg.GitHubToken != "" {
+			providers = append(providers, types.ProviderConfig{Type: "github", Token: cfg.GitHubToken})
+		}
+		if cfg.GitLabToken != "" {
+			providers = append(providers, types.ProviderConfig{Type: "gitlab", Token: cfg.GitLabToken, KeysetPagination: cfg.GitLabKeysetPagination})
+		}
+	}
+	if len(cfg.Remotes) > 0 {
+		providers = append(providers, types.ProviderConfig{Type: "git", Name: "remotes", Remotes: cfg.Remotes})
+	}
+
+	resolved := make([]types.ProviderConfig, len(providers))
+	for i, p := range providers {
+		if p.MaxPages == 0 {
+			p.MaxPages = cfg.MaxPages
+		}
+		resolved[i] = p
+	}
+	return resolved
+}
+
+// CacheTTL returns how long cached provider listings stay fresh: cfg.CacheTTL
+// parsed as a duration, or cache.DefaultTTL when it is not set. Zero means
+// listings never expire.
+func CacheTTL(cfg types.Config) (time.Duration, error) {
+	if cfg.CacheTTL == "" {
+		return cache.DefaultTTL, nil
+	}
+	ttl, err := time.ParseDuration(cfg.CacheTTL)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cache_ttl: %w", err)
+	}
+	if ttl < 0 {
+		return 0, fmt.Errorf("invalid cache_ttl: %s is negative", cfg.CacheTTL)
+	}
+	return ttl, nil
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"christopherharwell/project_monorepo/pkg/types"
+)
+
+func TestLoadConfig(t *testing.T) {
+	// Setup test config file
+	testConfig := `{
+		"github_token": "test_github",
+		"gitlab_token": "test_gitlab",
+		"use_subtree": true,
+		"auto_mode": true
+	}`
+	tmpFile, err := os.CreateTemp("", "config-*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write([]byte(testConfig)); err != nil {
+		t.Fatal(err)
+	}
+	tmpFile.Close()
+
+	cfg, err := LoadConfig(tmpFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GitHubToken != "test_github" {
+		t.Errorf("Expected GitHub token 'test_github', got '%s'", cfg.GitHubToken)
+	}
+	if !cfg.UseSubtree {
+		t.Error("Expected UseSubtree to be true")
+	}
+}
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	if _, err := LoadConfig("does-not-exist.json"); err == nil {
+		t.Error("Expected an error for a missing config file")
+	}
+}
+
+func TestProviders(t *testing.T) {
+	legacy := Providers(types.Config{GitHubToken: "gh", MaxPages: 3})
+	if len(legacy) != 1 || legacy[0].Type != "github" || legacy[0].Token != "gh" || legacy[0].MaxPages != 3 {
+		t.Errorf("Unexpected legacy providers: %+v", legacy)
+	}
+
+	cfg := types.Config{
+		GitHubToken: "ignored",
+		MaxPages:    5,
+		Providers: []types.ProviderConfig{
+			{Type: "gitlab", Name: "work", Token: "gl", MaxPages: 1},
+			{Type: "gitlab", Name: "personal", Token: "gl2"},
+		},
+	}
+	providers := Providers(cfg)
+	if len(providers) != 2 {
+		t.Fatalf("Expected 2 providers, got %d", len(providers))
+	}
+	if providers[0].MaxPages != 1 || providers[1].MaxPages != 5 {
+		t.Errorf("Unexpected page limits: %d, %d", providers[0].MaxPages, providers[1].MaxPages)
+	}
+}
+
+func TestProvidersWithRemotes(t *testing.T) {
+	cfg := types.Config{
+		GitHubToken: "gh",
+		Remotes:     []types.RemoteConfig{{URL: "nas:/volume1/git/notes.git"}},
+	}
+	providers := Providers(cfg)
+	if len(providers) != 2 {
+		t.Fatalf("Expected 2 providers, got %d", len(providers))
+	}
+	if providers[1].Type != "git" || providers[1].Name != "remotes" || len(providers[1].Remotes) != 1 {
+		t.Errorf("Unexpected remotes provider: %+v", providers[1])
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	tests := map[string]time.Duration{"": 24 * time.Hour, "0": 0, "90m": 90 * time.Minute}
+	for value, want := range tests {
+		got, err := CacheTTL(types.Config{CacheTTL: value})
+		if err != nil || got != want {
+			t.Errorf("CacheTTL(%q) = %v, %v, want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"1 day", "-1h"} {
+		if _, err := CacheTTL(types.Config{CacheTTL: value}); err == nil {
+			t.Errorf("CacheTTL(%q) accepted an invalid TTL", value)
+		}
+	}
+}
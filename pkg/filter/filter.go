@@ -0,0 +1,241 @@
+// Package filter applies the include and exclude rules of the configuration
+// to the fetched repositories and explains why each one was kept or dropped.
+package filter
+
+import (
+	"christopherharwell/project_monorepo/pkg/types"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Filter is a compiled set of include and exclude rules.
+type Filter struct {
+	include []rule
+	exclude []rule
+}
+
+// Decision records whether a repository was kept and which rule decided it.
+type Decision struct {
+	Repo     types.Repo
+	Included bool
+	Reason   string
+}
+
+// rule is a compiled types.FilterRule.
+type rule struct {
+	label        string
+	name         *regexp.Regexp
+	owner        *regexp.Regexp
+	provider     *regexp.Regexp
+	fork         *bool
+	archived     *bool
+	visibility   string
+	language     string
+	topics       []string
+	pushedBefore time.Time
+}
+
+// New compiles the rules of cfg.
+//
+// Parameters:
+//   - cfg: The include and exclude rules from the configuration
+//
+// Returns:
+//   - *Filter: The compiled filter
+//   - error: An error naming the rule with an invalid pattern or date
+func New(cfg types.FilterConfig) (*Filter, error) {
+	include, err := compileRules("include", cfg.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compileRules("exclude", cfg.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{include: include, exclude: exclude}, nil
+}
+
+func compileRules(kind string, cfgs []types.FilterRule) ([]rule, error) {
+	rules := make([]rule, len(cfgs))
+	for i, cfg := range cfgs {
+		r, err := compileRule(fmt.Sprintf("%s rule %d", kind, i+1), cfg)
+		if err != nil {
+			return nil, err
+		}
+		rules[i] = r
+	}
+	return rules, nil
+}
+
+func compileRule(label string, cfg types.FilterRule) (rule, error) {
+	r := rule{
+		fork:       cfg.Fork,
+		archived:   cfg.Archived,
+		visibility: cfg.Visibility,
+		language:   cfg.Language,
+		topics:     cfg.Topics,
+	}
+
+	var err error
+	if r.name, err = compilePattern(cfg.Name); err != nil {
+		return rule{}, fmt.Errorf("%s: name: %w", label, err)
+	}
+	if r.owner, err = compilePattern(cfg.Owner); err != nil {
+		return rule{}, fmt.Errorf("%s: owner: %w", label, err)
+	}
+	if r.provider, err = compilePattern(cfg.Provider); err != nil {
+		return rule{}, fmt.Errorf("%s: provider: %w", label, err)
+	}
+	if cfg.PushedBefore != "" {
+		if r.pushedBefore, err = parseDate(cfg.PushedBefore); err != nil {
+			return rule{}, fmt.Errorf("%s: pushed_before: %w", label, err)
+		}
+	}
+
+	r.label = fmt.Sprintf("%s (%s)", label, describe(cfg))
+	return r, nil
+}
+
+// compilePattern turns a glob or "re:" prefixed regular expression into a
+// case-insensitive regular expression matching the whole value. An empty
+// pattern compiles to nil, which matches anything.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	if expr, ok := strings.CutPrefix(pattern, "re:"); ok {
+		return regexp.Compile("(?i)" + expr)
+	}
+
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	for _, c := range pattern {
+		switch c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// describe lists the conditions set in cfg, e.g. "fork=true, owner=acme*".
+func describe(cfg types.FilterRule) string {
+	var conditions []string
+	add := func(key, value string) {
+		if value != "" {
+			conditions = append(conditions, key+"="+value)
+		}
+	}
+	add("name", cfg.Name)
+	add("owner", cfg.Owner)
+	add("provider", cfg.Provider)
+	if cfg.Fork != nil {
+		add("fork", fmt.Sprint(*cfg.Fork))
+	}
+	if cfg.Archived != nil {
+		add("archived", fmt.Sprint(*cfg.Archived))
+	}
+	add("visibility", cfg.Visibility)
+	add("language", cfg.Language)
+	add("topics", strings.Join(cfg.Topics, "|"))
+	add("pushed_before", cfg.PushedBefore)
+
+	if len(conditions) == 0 {
+		return "matches everything"
+	}
+	return strings.Join(conditions, ", ")
+}
+
+func (r rule) matches(repo types.Repo) bool {
+	switch {
+	case r.name != nil && !r.name.MatchString(repo.Name),
+		r.owner != nil && !r.owner.MatchString(repo.Owner),
+		r.provider != nil && !r.provider.MatchString(repo.Provider),
+		r.fork != nil && *r.fork != repo.Fork,
+		r.archived != nil && *r.archived != repo.Archived,
+		r.visibility != "" && !strings.EqualFold(r.visibility, repo.Visibility),
+		r.language != "" && !strings.EqualFold(r.language, repo.Language),
+		len(r.topics) > 0 && !hasAnyTopic(repo.Topics, r.topics),
+		!r.pushedBefore.IsZero() && (repo.PushedAt.IsZero() || !repo.PushedAt.Before(r.pushedBefore)):
+		return false
+	}
+	return true
+}
+
+func hasAnyTopic(topics []string, wanted []string) bool {
+	for _, topic := range topics {
+		for _, w := range wanted {
+			if strings.EqualFold(topic, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Evaluate decides whether repo is kept. Exclude rules take precedence over
+// include rules, and the first matching rule is reported as the reason.
+func (f *Filter) Evaluate(repo types.Repo) Decision {
+	for _, r := range f.exclude {
+		if r.matches(repo) {
+			return Decision{Repo: repo, Included: false, Reason: "excluded by " + r.label}
+		}
+	}
+	if len(f.include) == 0 {
+		return Decision{Repo: repo, Included: true, Reason: "no include rules configured"}
+	}
+	for _, r := range f.include {
+		if r.matches(repo) {
+			return Decision{Repo: repo, Included: true, Reason: "included by " + r.label}
+		}
+	}
+	return Decision{Repo: repo, Included: false, Reason: "no include rule matched"}
+}
+
+// Apply evaluates every repository and returns the ones kept, in order,
+// together with the decision taken for each repository.
+func (f *Filter) Apply(repos []types.Repo) ([]types.Repo, []Decision) {
+	var kept []types.Repo
+	decisions := make([]Decision, len(repos))
+	for i, repo := range repos {
+		decisions[i] = f.Evaluate(repo)
+		if decisions[i].Included {
+			kept = append(kept, repo)
+		}
+	}
+	return kept, decisions
+}
+
+// PrintDecisions prints which rule included or excluded each repository to w.
+func PrintDecisions(w io.Writer, decisions []Decision) {
+	fmt.Fprintln(w, "\nFilter decisions:")
+	included := 0
+	for _, d := range decisions {
+		verdict := "exclude"
+		if d.Included {
+			verdict = "include"
+			included++
+		}
+		name := d.Repo.FullPath
+		if name == "" {
+			name = d.Repo.Name
+		}
+		fmt.Fprintf(w, "  %s  %s:%s  %s\n", verdict, d.Repo.Provider, name, d.Reason)
+	}
+	fmt.Fprintf(w, "%d of %d repositories included\n\n", included, len(decisions))
+}
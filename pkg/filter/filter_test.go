@@ -0,0 +1,98 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"christopherharwell/project_monorepo/pkg/types"
+)
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+var testRepos = []types.Repo{
+	{Name: "api", Owner: "acme", Provider: "github", Visibility: "private", Language: "Go", Topics: []string{"backend"}, PushedAt: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+	{Name: "front-end", Owner: "acme", Provider: "github", Visibility: "public", Language: "TypeScript", Fork: true},
+	{Name: "budget-calculator-ui", Owner: "bootcamp", Provider: "github", Archived: true, PushedAt: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)},
+	{Name: "infra", Owner: "platform/ops", Provider: "gitlab-work", Visibility: "internal", Language: "HCL"},
+}
+
+func names(repos []types.Repo) string {
+	var n []string
+	for _, r := range repos {
+		n = append(n, r.Name)
+	}
+	return strings.Join(n, ",")
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  types.FilterConfig
+		want string
+	}{
+		{"no rules", types.FilterConfig{}, "api,front-end,budget-calculator-ui,infra"},
+		{"exclude forks and archived", types.FilterConfig{Exclude: []types.FilterRule{{Fork: boolPtr(true)}, {Archived: boolPtr(true)}}}, "api,infra"},
+		{"include owner glob", types.FilterConfig{Include: []types.FilterRule{{Owner: "ACME"}}}, "api,front-end"},
+		{"glob spans namespaces", types.FilterConfig{Include: []types.FilterRule{{Owner: "platform*"}}}, "infra"},
+		{"regex name", types.FilterConfig{Include: []types.FilterRule{{Name: "re:^(api|infra)$"}}}, "api,infra"},
+		{"provider glob", types.FilterConfig{Include: []types.FilterRule{{Provider: "gitlab-*"}}}, "infra"},
+		{"visibility and language", types.FilterConfig{Include: []types.FilterRule{{Visibility: "private", Language: "go"}}}, "api"},
+		{"topics", types.FilterConfig{Include: []types.FilterRule{{Topics: []string{"frontend", "Backend"}}}}, "api"},
+		{"pushed before", types.FilterConfig{Exclude: []types.FilterRule{{PushedBefore: "2023-01-01"}}}, "api,front-end,infra"},
+		{"exclude wins over include", types.FilterConfig{
+			Include: []types.FilterRule{{Owner: "acme"}},
+			Exclude: []types.FilterRule{{Name: "front-*"}},
+		}, "api"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			kept, decisions := f.Apply(testRepos)
+			if got := names(kept); got != tt.want {
+				t.Errorf("Apply kept %q, want %q", got, tt.want)
+			}
+			if len(decisions) != len(testRepos) {
+				t.Errorf("Expected a decision per repository, got %d", len(decisions))
+			}
+		})
+	}
+}
+
+func TestEvaluateReason(t *testing.T) {
+	f, err := New(types.FilterConfig{
+		Include: []types.FilterRule{{Owner: "acme"}},
+		Exclude: []types.FilterRule{{Fork: boolPtr(true)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"api":                  "included by include rule 1 (owner=acme)",
+		"front-end":            "excluded by exclude rule 1 (fork=true)",
+		"budget-calculator-ui": "no include rule matched",
+	}
+	for _, r := range testRepos[:3] {
+		if got := f.Evaluate(r).Reason; got != tests[r.Name] {
+			t.Errorf("Reason for %s = %q, want %q", r.Name, got, tests[r.Name])
+		}
+	}
+}
+
+func TestNewInvalidRules(t *testing.T) {
+	invalid := []types.FilterConfig{
+		{Include: []types.FilterRule{{Name: "re:("}}},
+		{Exclude: []types.FilterRule{{PushedBefore: "last year"}}},
+	}
+	for _, cfg := range invalid {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("Expected an error for %+v", cfg)
+		}
+	}
+}
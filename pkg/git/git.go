@@ -1,55 +1,763 @@
+// Package git wraps the git command line operations used to build and maintain
+// the monorepo: initialization, adding repositories as submodules or subtrees,
+// and pulling or pushing subtree changes.
 package git
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
+
+	"christopherharwell/project_monorepo/pkg/types"
 )
 
-func InitMonorepo() error {
-	absPath, err := filepath.Abs("monorepo")
+// ReposDir is the subdirectory of the monorepo that holds integrated repositories.
+const ReposDir = "repos"
+
+// InitMonorepo prepares the monorepo at dir. It creates the directory layout,
+// initializes a git repository with an initial commit when needed, makes sure
+// a main branch exists and verifies that the working tree is clean.
+//
+// Parameters:
+//   - dir: Path to the monorepo directory
+//
+// Returns:
+//   - error: Any error that occurred while preparing the monorepo
+func InitMonorepo(dir string) error {
+	absPath, err := filepath.Abs(dir)
 	if err != nil {
-		return err
+		return fmt.Errorf("error getting absolute path: %w", err)
 	}
 
 	if err := createMonorepoDirectories(absPath); err != nil {
 		return err
 	}
 
-	if err := initializeNewMonorepo(absPath); err != nil {
+	if !IsGitInitialized(absPath) {
+		if err := initializeNewMonorepo(absPath); err != nil {
+			return err
+		}
+	} else if err := ensureMainBranchExists(absPath); err != nil {
 		return err
 	}
 
-	return ensureMainBranchExists(absPath)
+	return VerifyCleanWorkingTree(absPath)
 }
 
 func createMonorepoDirectories(absPath string) error {
-	// Implementation moved from main.go
+	// Create the monorepo directory together with its repos subdirectory
+	if err := os.MkdirAll(filepath.Join(absPath, ReposDir), 0755); err != nil {
+		return fmt.Errorf("error creating monorepo directories: %w", err)
+	}
 	return nil
 }
 
 func initializeNewMonorepo(absPath string) error {
-	// Implementation moved from main.go
+	fmt.Printf("Initializing git repository in %s\n", absPath)
+
+	if err := run(absPath, "init", "-b", "main"); err != nil {
+		return fmt.Errorf("error initializing git repository: %w", err)
+	}
+
+	// Configure git user
+	if err := run(absPath, "config", "user.email", "monorepo@example.com"); err != nil {
+		return fmt.Errorf("error configuring git user: %w", err)
+	}
+	if err := run(absPath, "config", "user.name", "Monorepo"); err != nil {
+		return fmt.Errorf("error configuring git user: %w", err)
+	}
+
+	// Create and commit an initial file so the main branch exists
+	if err := os.WriteFile(filepath.Join(absPath, ".gitkeep"), []byte("initial"), 0644); err != nil {
+		return fmt.Errorf("error creating initial file: %w", err)
+	}
+	if err := run(absPath, "add", ".gitkeep"); err != nil {
+		return fmt.Errorf("error adding initial file: %w", err)
+	}
+	if err := run(absPath, "commit", "-m", "Initial commit"); err != nil {
+		return fmt.Errorf("error committing initial file: %w", err)
+	}
 	return nil
 }
 
 func ensureMainBranchExists(absPath string) error {
-	// Implementation moved from main.go
+	out, err := output(absPath, "branch", "--show-current")
+	if err == nil && out != "" {
+		return nil
+	}
+
+	// Create main branch if it doesn't exist
+	if err := run(absPath, "branch", "-M", "main"); err != nil {
+		return fmt.Errorf("error creating main branch: %w", err)
+	}
 	return nil
 }
 
-func VerifyCleanWorkingTree() error {
-	if !isCleanWorkingTree() {
-		return fmt.Errorf("working tree is not clean")
+// VerifyCleanWorkingTree returns an error when the git working tree at dir has
+// uncommitted changes or cannot be inspected.
+func VerifyCleanWorkingTree(dir string) error {
+	if !isCleanWorkingTree(dir) {
+		return fmt.Errorf("working tree is dirty, please commit or stash changes before continuing")
 	}
 	return nil
 }
 
-func isCleanWorkingTree() bool {
-	// Implementation moved from main.go
-	return false
+func isCleanWorkingTree(dir string) bool {
+	out, err := output(dir, "status", "--porcelain")
+	if err != nil {
+		return false
+	}
+	return out == ""
 }
 
+// IsGitInitialized reports whether dir is inside a git repository.
 func IsGitInitialized(dir string) bool {
-	// Implementation moved from main.go
-	return false
-} 
\ No newline at end of file
+	cmd := exec.Command("git", "rev-parse", "--git-dir")
+	cmd.Dir = dir
+	cmd.Stdout = io.Discard
+	cmd.Stderr = io.Discard
+	return cmd.Run() == nil
+}
+
+// AddRepos integrates the given repositories into the monorepo at dir using
+// either git subtree or git submodule, retries the ones that failed once and
+// commits the result. Repositories with a Commit are pinned to that commit,
+// the others get the tip of their default branch. Repositories that already
+// exist are skipped; the ones that still fail after the retry are listed in
+// the returned error.
+//
+// Parameters:
+//   - dir: Path to the monorepo directory
+//   - repos: The repositories to add
+//   - useSubtree: Whether to use git subtree instead of git submodule
+//
+// Returns:
+//   - []types.Repo: The repositories that were added successfully, with Commit set to the integrated commit
+//   - error: Any error that prevented adding or committing the repositories
+func AddRepos(dir string, repos []types.Repo, useSubtree bool) ([]types.Repo, error) {
+	return addRepos(dir, repos, useSubtree, true)
+}
+
+// ImportRepos works like AddRepos, except that subtrees keep the full history
+// of their repository instead of a single squash commit. It is meant for
+// repositories that exist nowhere else, such as local repositories without a
+// remote, whose history would otherwise only survive outside the monorepo.
+// Later updates of an imported subtree are squashed like any other.
+//
+// Parameters:
+//   - dir: Path to the monorepo directory
+//   - repos: The repositories to import; SSHURL may be a local path
+//   - useSubtree: Whether to use git subtree instead of git submodule
+//
+// Returns:
+//   - []types.Repo: The repositories that were imported successfully, with Commit set to the integrated commit
+//   - error: Any error that prevented importing or committing the repositories
+func ImportRepos(dir string, repos []types.Repo, useSubtree bool) ([]types.Repo, error) {
+	return addRepos(dir, repos, useSubtree, false)
+}
+
+func addRepos(dir string, repos []types.Repo, useSubtree bool, squash bool) ([]types.Repo, error) {
+	if err := VerifyCleanWorkingTree(dir); err != nil {
+		return nil, err
+	}
+
+	failed, success := attemptAdd(dir, repos, useSubtree, squash, nil)
+	if len(failed) > 0 {
+		fmt.Println("\nRetrying failed repositories...")
+		failed, success = attemptAdd(dir, failed, useSubtree, squash, success)
+	}
+	var failedNames []string
+	for _, r := range failed {
+		fmt.Printf("Failed to add repository %s\n", r.Name)
+		failedNames = append(failedNames, r.Name)
+	}
+
+	if len(success) == 0 {
+		fmt.Println("No repositories were successfully added.")
+		return nil, failedError("add", "repositories", failedNames)
+	}
+
+	// Subtree additions commit on their own; submodules are staged only
+	if !isCleanWorkingTree(dir) {
+		if err := runVisible(dir, nil, "commit", "-m", "Add selected repos"); err != nil {
+			return success, fmt.Errorf("error committing added repositories: %w", err)
+		}
+	}
+	return success, failedError("add", "repositories", failedNames)
+}
+
+func attemptAdd(dir string, repos []types.Repo, useSubtree bool, squash bool, success []types.Repo) ([]types.Repo, []types.Repo) {
+	var failed []types.Repo
+	for _, r := range repos {
+		if RepoExists(dir, r) {
+			fmt.Printf("Skipping %s: %s already exists\n", r.Name, RepoPath(r))
+			continue
+		}
+
+		commit, err := addRepo(dir, r, useSubtree, squash)
+		if err != nil {
+			fmt.Printf("Error adding repository %s: %v\n", r.Name, err)
+			failed = append(failed, r)
+			continue
+		}
+		r.Commit = commit
+		success = append(success, r)
+	}
+	return failed, success
+}
+
+// addRepo integrates r at its path and returns the upstream commit that was
+// added. Subtrees are squashed into a single commit when squash is set.
+func addRepo(dir string, r types.Repo, useSubtree bool, squash bool) (string, error) {
+	prefix := RepoPath(r)
+	fmt.Printf("\nAttempting to add repository: %s\n", r.Name)
+
+	var commit string
+	var err error
+	if useSubtree {
+		commit, err = addSubtree(dir, r, prefix, squash)
+	} else {
+		commit, err = addSubmodule(dir, r, prefix)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Printf("Successfully added %s at %s\n", r.Name, ShortCommit(commit))
+	return commit, nil
+}
+
+func addSubtree(dir string, r types.Repo, prefix string, squash bool) (string, error) {
+	commit, err := fetchCommit(dir, r.SSHURL, r.DefaultBranch, r.Commit)
+	if err != nil {
+		return "", err
+	}
+	args := []string{"subtree", "add", "--prefix", prefix, commit}
+	if squash {
+		args = append(args, "--squash")
+	}
+	if err := runLogged(dir, nil, args...); err != nil {
+		return "", err
+	}
+	return commit, nil
+}
+
+func addSubmodule(dir string, r types.Repo, prefix string) (string, error) {
+	args := []string{"submodule", "add"}
+	// Since 2.38.1 git refuses to clone submodules from local paths unless
+	// the file transport is allowed, which it is for a URL chosen by the user
+	if IsLocalURL(r.SSHURL) {
+		args = append([]string{"-c", "protocol.file.allow=always"}, args...)
+	}
+	// The URL is recorded in .gitmodules, so a token embedded in the clone URL
+	// is left out of it and only used to clone, by rewriting the plain URL
+	plainURL, env := credentialEnv(r.SSHURL)
+	if r.DefaultBranch != "" {
+		args = append(args, "-b", r.DefaultBranch)
+	}
+	if err := runLogged(dir, env, append(args, plainURL, prefix)...); err != nil {
+		return "", err
+	}
+
+	submodule := filepath.Join(dir, prefix)
+	if r.Commit != "" {
+		commit, err := fetchCommit(submodule, r.SSHURL, r.DefaultBranch, r.Commit)
+		if err != nil {
+			return "", err
+		}
+		if err := capture(submodule, "checkout", "-q", commit); err != nil {
+			return "", err
+		}
+		if err := run(dir, "add", prefix); err != nil {
+			return "", fmt.Errorf("error staging %s: %w", prefix, err)
+		}
+	}
+	return output(submodule, "rev-parse", "HEAD")
+}
+
+// fetchCommit fetches branch from repoURL into the repository at dir and
+// returns the commit to integrate: pinned when set, the fetched tip otherwise.
+// A pinned commit that is no longer on the branch is requested directly.
+func fetchCommit(dir string, repoURL string, branch string, pinned string) (string, error) {
+	if branch == "" {
+		branch = "HEAD"
+	}
+	fetchURL, env := credentialEnv(repoURL)
+	if err := captureEnv(dir, env, "fetch", "--no-tags", fetchURL, branch); err != nil {
+		return "", fmt.Errorf("error fetching %s from %s: %w", branch, RedactURL(repoURL), err)
+	}
+	if pinned == "" {
+		return output(dir, "rev-parse", "FETCH_HEAD")
+	}
+
+	if run(dir, "cat-file", "-e", pinned+"^{commit}") != nil {
+		if err := captureEnv(dir, env, "fetch", "--no-tags", fetchURL, pinned); err != nil {
+			return "", fmt.Errorf("error fetching commit %s from %s: %w", pinned, RedactURL(repoURL), err)
+		}
+	}
+	return output(dir, "rev-parse", pinned+"^{commit}")
+}
+
+// RepoPath returns the path of the repository relative to the monorepo root,
+// using r.Path when set and r.Name otherwise.
+func RepoPath(r types.Repo) string {
+	if r.Path != "" {
+		return filepath.Join(ReposDir, filepath.FromSlash(r.Path))
+	}
+	return filepath.Join(ReposDir, r.Name)
+}
+
+// RepoExists reports whether the repository already has a directory in the
+// monorepo at dir.
+func RepoExists(dir string, r types.Repo) bool {
+	_, err := os.Stat(filepath.Join(dir, RepoPath(r)))
+	return err == nil
+}
+
+// RemoveRepo removes the repository at its path from the monorepo at dir.
+// Submodules are deinitialized and their git directory is deleted. The
+// removal is staged but not committed.
+func RemoveRepo(dir string, r types.Repo) error {
+	prefix := RepoPath(r)
+	if !RepoExists(dir, r) {
+		return fmt.Errorf("%s does not exist", prefix)
+	}
+
+	submodules, err := Submodules(dir)
+	if err != nil {
+		return err
+	}
+	sm, isSubmodule := submodules[filepath.ToSlash(prefix)]
+	if !isSubmodule {
+		if err := capture(dir, "rm", "-r", "-q", "--", prefix); err != nil {
+			return fmt.Errorf("error removing %s: %w", prefix, err)
+		}
+		// Untracked files are left behind by git rm
+		return os.RemoveAll(filepath.Join(dir, prefix))
+	}
+
+	if err := capture(dir, "submodule", "deinit", "-f", "--", prefix); err != nil {
+		return fmt.Errorf("error deinitializing %s: %w", prefix, err)
+	}
+	if err := capture(dir, "rm", "-f", "-q", "--", prefix); err != nil {
+		return fmt.Errorf("error removing %s: %w", prefix, err)
+	}
+	gitDir, err := output(dir, "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return fmt.Errorf("error locating the git directory: %w", err)
+	}
+	return os.RemoveAll(filepath.Join(gitDir, "modules", sm.Name))
+}
+
+// Update describes how an integrated repository moved to a new upstream commit.
+type Update struct {
+	// Repo is the updated repository, with Commit set to NewCommit
+	Repo types.Repo
+
+	// OldCommit is the previously integrated commit; it is empty when unknown
+	OldCommit string
+
+	// NewCommit is the commit now integrated
+	NewCommit string
+
+	// Commits is the number of commits between OldCommit and NewCommit, or -1 when unknown
+	Commits int
+}
+
+// String formats the update as an old..new range with its commit count.
+func (u Update) String() string {
+	switch {
+	case u.OldCommit == u.NewCommit:
+		return fmt.Sprintf("%s: up to date at %s", RepoPath(u.Repo), ShortCommit(u.NewCommit))
+	case u.OldCommit == "":
+		return fmt.Sprintf("%s: now at %s", RepoPath(u.Repo), ShortCommit(u.NewCommit))
+	case u.Commits < 0:
+		return fmt.Sprintf("%s: %s..%s (history rewritten upstream)", RepoPath(u.Repo), ShortCommit(u.OldCommit), ShortCommit(u.NewCommit))
+	}
+	return fmt.Sprintf("%s: %s..%s (%d commit(s))", RepoPath(u.Repo), ShortCommit(u.OldCommit), ShortCommit(u.NewCommit), u.Commits)
+}
+
+// ShortCommit abbreviates a commit hash for display.
+func ShortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+// UpdateSubtrees merges the tip of each repository's default branch into its
+// subtree. The previously integrated commit is taken from r.Commit or, when
+// empty, from the last squash commit of the subtree. Every repository is
+// attempted; the returned error lists the ones that failed.
+//
+// Parameters:
+//   - dir: Path to the monorepo directory
+//   - repos: The subtree repositories to update
+//
+// Returns:
+//   - []Update: The old and new commit of each repository updated successfully
+//   - error: An error listing the repositories that could not be updated
+func UpdateSubtrees(dir string, repos []types.Repo) ([]Update, error) {
+	var updates []Update
+	var failed []string
+	for _, r := range repos {
+		fmt.Printf("Updating subtree: %s\n", r.Name)
+		u, err := updateSubtree(dir, r)
+		if err != nil {
+			fmt.Printf("Error updating %s: %v\n", r.Name, err)
+			failed = append(failed, r.Name)
+			continue
+		}
+		fmt.Printf("  %s\n", u)
+		updates = append(updates, u)
+	}
+	fmt.Println("Subtree updates complete.")
+	return updates, failedError("update", "subtrees", failed)
+}
+
+func updateSubtree(dir string, r types.Repo) (Update, error) {
+	prefix := RepoPath(r)
+	if !RepoExists(dir, r) {
+		return Update{}, fmt.Errorf("%s does not exist", prefix)
+	}
+
+	oldCommit := r.Commit
+	if oldCommit == "" {
+		oldCommit = subtreeCommit(dir, prefix)
+	}
+	newCommit, err := fetchCommit(dir, r.SSHURL, r.DefaultBranch, "")
+	if err != nil {
+		return Update{}, err
+	}
+
+	r.Commit = newCommit
+	u := Update{Repo: r, OldCommit: oldCommit, NewCommit: newCommit, Commits: countCommits(dir, oldCommit, newCommit)}
+	if oldCommit == newCommit {
+		return u, nil
+	}
+	if err := capture(dir, "subtree", "merge", "--prefix", prefix, newCommit, "--squash"); err != nil {
+		return Update{}, err
+	}
+	return u, nil
+}
+
+// subtreeCommit returns the upstream commit recorded by the last squash, or
+// full-history add, of the subtree at prefix, or an empty string when none is found.
+func subtreeCommit(dir string, prefix string) string {
+	out, err := output(dir, "log", "-1", "--format=%B", "--grep=^git-subtree-dir: "+filepath.ToSlash(prefix)+"/*$")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if commit, ok := strings.CutPrefix(strings.TrimSpace(line), "git-subtree-split: "); ok {
+			return commit
+		}
+	}
+	return ""
+}
+
+// UpdateSubmodules checks out the tip of each repository's default branch in
+// its submodule and commits the new submodule commits. Every repository is
+// attempted; the returned error lists the ones that failed.
+//
+// Parameters:
+//   - dir: Path to the monorepo directory
+//   - repos: The submodule repositories to update
+//
+// Returns:
+//   - []Update: The old and new commit of each repository updated successfully
+//   - error: An error listing the repositories that could not be updated
+func UpdateSubmodules(dir string, repos []types.Repo) ([]Update, error) {
+	var updates []Update
+	var failed, paths []string
+	for _, r := range repos {
+		fmt.Printf("Updating submodule: %s\n", r.Name)
+		u, err := updateSubmodule(dir, r)
+		if err != nil {
+			fmt.Printf("Error updating %s: %v\n", r.Name, err)
+			failed = append(failed, r.Name)
+			continue
+		}
+		fmt.Printf("  %s\n", u)
+		updates = append(updates, u)
+		paths = append(paths, RepoPath(r))
+	}
+
+	if len(paths) > 0 {
+		if err := Commit(dir, "Update submodules", paths...); err != nil {
+			return nil, err
+		}
+	}
+	fmt.Println("Submodule updates complete.")
+	return updates, failedError("update", "submodules", failed)
+}
+
+func updateSubmodule(dir string, r types.Repo) (Update, error) {
+	submodule := filepath.Join(dir, RepoPath(r))
+	oldCommit, err := output(submodule, "rev-parse", "HEAD")
+	if err != nil {
+		return Update{}, fmt.Errorf("%s is not a checked out submodule: %w", RepoPath(r), err)
+	}
+	newCommit, err := fetchCommit(submodule, r.SSHURL, r.DefaultBranch, "")
+	if err != nil {
+		return Update{}, err
+	}
+
+	r.Commit = newCommit
+	u := Update{Repo: r, OldCommit: oldCommit, NewCommit: newCommit, Commits: countCommits(submodule, oldCommit, newCommit)}
+	if oldCommit == newCommit {
+		return u, nil
+	}
+	if err := capture(submodule, "checkout", "-q", newCommit); err != nil {
+		return Update{}, err
+	}
+	return u, nil
+}
+
+// countCommits returns the number of commits from oldCommit to newCommit, or
+// -1 when oldCommit is unknown or not an ancestor of newCommit.
+func countCommits(dir string, oldCommit string, newCommit string) int {
+	if oldCommit == "" {
+		return -1
+	}
+	if oldCommit == newCommit {
+		return 0
+	}
+	if run(dir, "merge-base", "--is-ancestor", oldCommit, newCommit) != nil {
+		return -1
+	}
+	out, err := output(dir, "rev-list", "--count", oldCommit+".."+newCommit)
+	if err != nil {
+		return -1
+	}
+	n, err := strconv.Atoi(out)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// PushSubtrees pushes each repository's subtree back to its default branch.
+// Every repository is attempted; the returned error lists the ones that failed.
+func PushSubtrees(dir string, repos []types.Repo) error {
+	var failed []string
+	for _, r := range repos {
+		fmt.Printf("Pushing subtree: %s\n", r.Name)
+		pushURL, env := credentialEnv(r.SSHURL)
+		if err := runVisible(dir, env, "subtree", "push", "--prefix", RepoPath(r), pushURL, r.DefaultBranch); err != nil {
+			failed = append(failed, r.Name)
+		}
+	}
+	fmt.Println("Subtree pushes complete.")
+	return failedError("push", "subtrees", failed)
+}
+
+// Commit stages the given paths of the monorepo at dir and commits them with
+// message. Nothing is committed when the paths have no changes.
+func Commit(dir string, message string, paths ...string) error {
+	if err := run(dir, append([]string{"add", "--"}, paths...)...); err != nil {
+		return fmt.Errorf("error staging %s: %w", strings.Join(paths, ", "), err)
+	}
+	if run(dir, "diff", "--cached", "--quiet") == nil {
+		return nil
+	}
+	if err := run(dir, "commit", "-m", message); err != nil {
+		return fmt.Errorf("error committing %s: %w", strings.Join(paths, ", "), err)
+	}
+	return nil
+}
+
+// Submodule is an entry of the monorepo's .gitmodules file.
+type Submodule struct {
+	// Name is the name of the submodule section
+	Name string
+
+	// Path is the location of the submodule relative to the monorepo root
+	Path string
+
+	// URL is the remote the submodule was added from
+	URL string
+
+	// Branch is the branch the submodule tracks, if any
+	Branch string
+}
+
+// Submodules reads the .gitmodules file of the monorepo at dir and returns
+// its entries keyed by slash-separated path. A missing file yields no entries.
+func Submodules(dir string) (map[string]Submodule, error) {
+	submodules := map[string]Submodule{}
+	if _, err := os.Stat(filepath.Join(dir, ".gitmodules")); os.IsNotExist(err) {
+		return submodules, nil
+	}
+
+	out, err := output(dir, "config", "-f", ".gitmodules", "--get-regexp", `^submodule\.`)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		// git config exits with 1 when no key matches
+		return submodules, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading .gitmodules: %w", err)
+	}
+
+	byName := map[string]*Submodule{}
+	var names []string
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		key = strings.TrimPrefix(key, "submodule.")
+		dot := strings.LastIndex(key, ".")
+		if dot < 0 {
+			continue
+		}
+		name, field := key[:dot], key[dot+1:]
+		sm, ok := byName[name]
+		if !ok {
+			sm = &Submodule{Name: name}
+			byName[name] = sm
+			names = append(names, name)
+		}
+		switch field {
+		case "path":
+			sm.Path = filepath.ToSlash(value)
+		case "url":
+			sm.URL = value
+		case "branch":
+			sm.Branch = value
+		}
+	}
+
+	for _, name := range names {
+		if sm := byName[name]; sm.Path != "" {
+			submodules[sm.Path] = *sm
+		}
+	}
+	return submodules, nil
+}
+
+// RedactURL hides any credentials embedded in a repository URL so it can be
+// printed safely. URLs without user information are returned unchanged.
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
+
+// StripCredentials removes user information from http(s) URLs so clone URLs
+// with embedded tokens can be stored or compared with the plain URL.
+func StripCredentials(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return rawURL
+	}
+	u.User = nil
+	return u.String()
+}
+
+// credentialEnv returns the URL to pass to git for repoURL and the
+// environment git needs to reach it. Credentials embedded in repoURL are left
+// out of the URL and handed to git as an insteadOf rewrite of the plain URL
+// in its environment, as command lines are visible to every local user.
+func credentialEnv(repoURL string) (string, []string) {
+	plainURL := StripCredentials(repoURL)
+	if plainURL == repoURL {
+		return repoURL, nil
+	}
+	// Entries of GIT_CONFIG_COUNT set by the caller are kept
+	n, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	return plainURL, []string{
+		fmt.Sprintf("GIT_CONFIG_COUNT=%d", n+1),
+		fmt.Sprintf("GIT_CONFIG_KEY_%d=url.%s.insteadOf", n, repoURL),
+		fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", n, plainURL),
+	}
+}
+
+// IsLocalURL reports whether rawURL names a repository on the local file
+// system, as a path or file:// URL, rather than on a host.
+func IsLocalURL(rawURL string) bool {
+	if strings.HasPrefix(rawURL, "file://") {
+		return true
+	}
+	if strings.Contains(rawURL, "://") {
+		return false
+	}
+	// scp-like syntax, [user@]host:path, has a colon before any slash
+	i := strings.Index(rawURL, ":")
+	return i <= 0 || strings.Contains(rawURL[:i], "/") || filepath.IsAbs(rawURL)
+}
+
+func failedError(op string, kind string, failed []string) error {
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("failed to %s %s: %s", op, kind, strings.Join(failed, ", "))
+}
+
+func run(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	return cmd.Run()
+}
+
+// runLogged prints the git command and runs it with env added to the
+// environment, including its output in the returned error.
+func runLogged(dir string, env []string, args ...string) error {
+	fmt.Printf("Running command: git %s\n", strings.Join(args, " "))
+	return captureEnv(dir, env, args...)
+}
+
+// capture runs git and includes its output in the returned error.
+func capture(dir string, args ...string) error {
+	return captureEnv(dir, nil, args...)
+}
+
+// captureEnv runs git with env added to the environment and includes its
+// output in the returned error.
+func captureEnv(dir string, env []string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w\nStdout: %s\nStderr: %s", err, stdout.String(), stderr.String())
+	}
+	return nil
+}
+
+func runVisible(dir string, env []string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func output(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,212 @@
+// Package gitea implements a provider for Gitea and Forgejo instances.
+package gitea
+
+import (
+	"christopherharwell/project_monorepo/pkg/httputil"
+	"christopherharwell/project_monorepo/pkg/provider"
+	"christopherharwell/project_monorepo/pkg/types"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pageSize is the number of items requested per page. Gitea caps the limit
+// parameter at its MAX_RESPONSE_ITEMS setting, which defaults to 50.
+const pageSize = 50
+
+func init() {
+	provider.Register("gitea", NewProvider)
+	provider.Register("forgejo", NewProvider)
+}
+
+// giteaProvider lists the repositories of a Gitea or Forgejo user and of the
+// organizations the user belongs to.
+type giteaProvider struct {
+	name     string
+	apiURL   string
+	gitHost  string
+	token    string
+	maxPages int
+	client   *httputil.Client
+}
+
+// repository is the subset of the Gitea repository model used here.
+type repository struct {
+	ID            int64     `json:"id"`
+	Name          string    `json:"name"`
+	FullName      string    `json:"full_name"`
+	SSHURL        string    `json:"ssh_url"`
+	CloneURL      string    `json:"clone_url"`
+	HTMLURL       string    `json:"html_url"`
+	DefaultBranch string    `json:"default_branch"`
+	Description   string    `json:"description"`
+	Language      string    `json:"language"`
+	Topics        []string  `json:"topics"`
+	Private       bool      `json:"private"`
+	Internal      bool      `json:"internal"`
+	Fork          bool      `json:"fork"`
+	Archived      bool      `json:"archived"`
+	Stars         int       `json:"stars_count"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Owner         struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+}
+
+// organization is the subset of the Gitea organization model used here.
+type organization struct {
+	UserName string `json:"username"`
+}
+
+// NewProvider creates a Gitea provider. cfg.APIURL must point at the API root
+// of the instance, e.g. "https://codeberg.org/api/v1"; cfg.GitHost optionally
+// overrides the host of the clone URLs.
+func NewProvider(cfg types.ProviderConfig) (provider.Provider, error) {
+	name := provider.InstanceName(cfg)
+	if cfg.APIURL == "" {
+		return nil, fmt.Errorf("provider %q: api_url is required for %s", name, cfg.Type)
+	}
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("provider %q: token is empty", name)
+	}
+	return &giteaProvider{
+		name:     name,
+		apiURL:   strings.TrimSuffix(cfg.APIURL, "/"),
+		gitHost:  cfg.GitHost,
+		token:    cfg.Token,
+		maxPages: cfg.MaxPages,
+		client:   httputil.NewClient("Gitea", nil),
+	}, nil
+}
+
+func (p *giteaProvider) Name() string {
+	return p.name
+}
+
+// ListRepos returns the repositories of the authenticated user followed by
+// those of each of the user's organizations. Repositories returned by more
+// than one listing are only included once.
+func (p *giteaProvider) ListRepos(ctx context.Context) ([]types.Repo, error) {
+	listings := []string{p.apiURL + "/user/repos"}
+
+	orgs, err := fetchAll[organization](ctx, p, p.apiURL+"/user/orgs")
+	if err != nil {
+		return nil, err
+	}
+	for _, org := range orgs {
+		if org.UserName != "" {
+			listings = append(listings, fmt.Sprintf("%s/orgs/%s/repos", p.apiURL, url.PathEscape(org.UserName)))
+		}
+	}
+
+	var repos []types.Repo
+	seen := map[string]bool{}
+	for _, listing := range listings {
+		data, err := fetchAll[repository](ctx, p, listing)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range data {
+			if r.FullName != "" && seen[r.FullName] {
+				continue
+			}
+			seen[r.FullName] = true
+			repos = append(repos, toRepo(r))
+		}
+	}
+	return repos, nil
+}
+
+func toRepo(r repository) types.Repo {
+	visibility := "public"
+	if r.Internal {
+		visibility = "internal"
+	} else if r.Private {
+		visibility = "private"
+	}
+	repo := types.Repo{
+		Name:          r.Name,
+		SSHURL:        r.SSHURL,
+		DefaultBranch: r.DefaultBranch,
+		Owner:         r.Owner.Login,
+		FullPath:      r.FullName,
+		HTTPSURL:      r.CloneURL,
+		WebURL:        r.HTMLURL,
+		Description:   r.Description,
+		Language:      r.Language,
+		Topics:        r.Topics,
+		Visibility:    visibility,
+		Fork:          r.Fork,
+		Archived:      r.Archived,
+		Stars:         r.Stars,
+		CreatedAt:     r.CreatedAt,
+		PushedAt:      r.UpdatedAt,
+	}
+	if r.ID != 0 {
+		repo.ID = strconv.FormatInt(r.ID, 10)
+	}
+	return repo
+}
+
+// CloneURL returns the SSH URL of r, on the configured git host if any;
+// Gitea repositories are cloned over SSH.
+func (p *giteaProvider) CloneURL(r types.Repo) string {
+	return provider.RewriteHost(r.SSHURL, p.gitHost)
+}
+
+func (p *giteaProvider) AuthMethod() provider.AuthMethod {
+	return provider.AuthSSH
+}
+
+// fetchAll requests every page of a listing endpoint, following the
+// rel="next" Link header until the last page or the page limit is reached.
+func fetchAll[T any](ctx context.Context, p *giteaProvider, listing string) ([]T, error) {
+	var items []T
+	pages := 0
+	next := fmt.Sprintf("%s?limit=%d", listing, pageSize)
+	for next != "" {
+		if p.maxPages > 0 && pages == p.maxPages {
+			fmt.Fprintf(os.Stderr, "Warning: %s listing %s stopped at the %d page limit\n", p.name, listing, pages)
+			break
+		}
+
+		var page []T
+		var err error
+		next, err = p.getJSON(ctx, next, &page)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page...)
+		pages++
+	}
+	return items, nil
+}
+
+// getJSON decodes the response of a GET request for url into v and returns
+// the URL of the next page, or an empty string on the last page.
+func (p *giteaProvider) getJSON(ctx context.Context, url string, v interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "token "+p.token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", fmt.Errorf("error decoding Gitea response (URL: %s): %w", url, err)
+	}
+	return httputil.NextLink(resp.Header.Get("Link")), nil
+}
@@ -0,0 +1,130 @@
+package gitea
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"christopherharwell/project_monorepo/pkg/provider"
+	"christopherharwell/project_monorepo/pkg/types"
+)
+
+// newFakeServer serves a Gitea API with two pages of user repositories and
+// one organization whose listing repeats a user repository.
+func newFakeServer(t *testing.T) *httptest.Server {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message": "token is required"}`))
+			return
+		}
+		if r.URL.Query().Get("limit") != "50" {
+			t.Errorf("Expected limit=50, got %q", r.URL.RawQuery)
+		}
+
+		switch r.URL.Path {
+		case "/api/v1/user/repos":
+			if r.URL.Query().Get("page") == "2" {
+				w.Write([]byte(`[{"name": "notes", "full_name": "me/notes", "ssh_url": "git@forgejo.test:me/notes.git", "default_branch": "trunk"}]`))
+				return
+			}
+			w.Header().Set("Link", fmt.Sprintf(`<%s/api/v1/user/repos?limit=50&page=2>; rel="next", <%s/api/v1/user/repos?limit=50&page=2>; rel="last"`, ts.URL, ts.URL))
+			w.Write([]byte(`[
+				{"name": "dotfiles", "full_name": "me/dotfiles", "ssh_url": "git@forgejo.test:me/dotfiles.git", "default_branch": "main",
+				 "owner": {"login": "me"}, "private": true, "fork": true, "stars_count": 4, "language": "Shell", "topics": ["config"],
+				 "html_url": "https://forgejo.test/me/dotfiles", "created_at": "2023-01-02T03:04:05Z"},
+				{"name": "site", "full_name": "club/site", "ssh_url": "git@forgejo.test:club/site.git", "default_branch": "main"}
+			]`))
+		case "/api/v1/user/orgs":
+			w.Write([]byte(`[{"id": 7, "username": "club"}]`))
+		case "/api/v1/orgs/club/repos":
+			w.Write([]byte(`[
+				{"name": "site", "full_name": "club/site", "ssh_url": "git@forgejo.test:club/site.git", "default_branch": "main"},
+				{"name": "bot", "full_name": "club/bot", "ssh_url": "git@forgejo.test:club/bot.git", "default_branch": "develop"}
+			]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return ts
+}
+
+func TestListRepos(t *testing.T) {
+	ts := newFakeServer(t)
+	defer ts.Close()
+
+	p, err := provider.New(types.ProviderConfig{Type: "forgejo", Token: "test-token", APIURL: ts.URL + "/api/v1/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repos, err := p.ListRepos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []types.Repo{
+		{Name: "dotfiles", SSHURL: "git@forgejo.test:me/dotfiles.git", DefaultBranch: "main"},
+		{Name: "site", SSHURL: "git@forgejo.test:club/site.git", DefaultBranch: "main"},
+		{Name: "notes", SSHURL: "git@forgejo.test:me/notes.git", DefaultBranch: "trunk"},
+		{Name: "bot", SSHURL: "git@forgejo.test:club/bot.git", DefaultBranch: "develop"},
+	}
+	if len(repos) != len(want) {
+		t.Fatalf("Expected %d repos, got %d: %+v", len(want), len(repos), repos)
+	}
+	for i := range want {
+		if repos[i].Name != want[i].Name || repos[i].SSHURL != want[i].SSHURL || repos[i].DefaultBranch != want[i].DefaultBranch {
+			t.Errorf("Repo %d: expected %+v, got %+v", i, want[i], repos[i])
+		}
+	}
+	dotfiles := repos[0]
+	if dotfiles.Owner != "me" || dotfiles.FullPath != "me/dotfiles" || dotfiles.Visibility != "private" || !dotfiles.Fork || dotfiles.Stars != 4 {
+		t.Errorf("Unexpected metadata: %+v", dotfiles)
+	}
+	if dotfiles.Language != "Shell" || len(dotfiles.Topics) != 1 || dotfiles.WebURL != "https://forgejo.test/me/dotfiles" || dotfiles.CreatedAt.Year() != 2023 {
+		t.Errorf("Unexpected metadata: %+v", dotfiles)
+	}
+	if got := p.CloneURL(repos[0]); got != "git@forgejo.test:me/dotfiles.git" {
+		t.Errorf("Unexpected clone URL: %s", got)
+	}
+}
+
+func TestListReposMaxPages(t *testing.T) {
+	ts := newFakeServer(t)
+	defer ts.Close()
+
+	p, err := NewProvider(types.ProviderConfig{Type: "gitea", Token: "test-token", APIURL: ts.URL + "/api/v1", MaxPages: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repos, err := p.ListRepos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 3 {
+		t.Errorf("Expected 3 repos with a 1 page limit, got %d", len(repos))
+	}
+}
+
+func TestListReposUnauthorized(t *testing.T) {
+	ts := newFakeServer(t)
+	defer ts.Close()
+
+	p, err := NewProvider(types.ProviderConfig{Type: "gitea", Token: "wrong", APIURL: ts.URL + "/api/v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ListRepos(context.Background()); err == nil {
+		t.Error("Expected an error for an unauthorized token")
+	}
+}
+
+func TestNewProviderRequiresAPIURL(t *testing.T) {
+	if _, err := NewProvider(types.ProviderConfig{Type: "gitea", Token: "test-token"}); err == nil {
+		t.Error("Expected an error without api_url")
+	}
+}
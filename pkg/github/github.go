@@ -1,16 +1,116 @@
 package github
 
 import (
+	"christopherharwell/project_monorepo/pkg/httputil"
+	"christopherharwell/project_monorepo/pkg/provider"
 	"christopherharwell/project_monorepo/pkg/types"
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
-func NewClient() *http.Client {
-	return &http.Client{Timeout: 10 * time.Second}
+// DefaultAPIURL is the REST API root of github.com. GitHub Enterprise Server
+// instances serve the API at https://<host>/api/v3.
+const DefaultAPIURL = "https://api.github.com"
+
+func init() {
+	provider.Register("github", NewProvider)
+}
+
+// githubProvider lists the repositories of a GitHub or GitHub Enterprise account.
+type githubProvider struct {
+	name     string
+	apiURL   string
+	gitHost  string
+	token    string
+	maxPages int
+	pages    *httputil.PageCache
+}
+
+// repository is the subset of the GitHub repository model used here. Fields
+// that are null, such as the default branch and language of an empty
+// repository, are left empty.
+type repository struct {
+	ID            int64     `json:"id"`
+	Name          string    `json:"name"`
+	FullName      string    `json:"full_name"`
+	SSHURL        string    `json:"ssh_url"`
+	CloneURL      string    `json:"clone_url"`
+	HTMLURL       string    `json:"html_url"`
+	DefaultBranch string    `json:"default_branch"`
+	Description   string    `json:"description"`
+	Language      string    `json:"language"`
+	Topics        []string  `json:"topics"`
+	Visibility    string    `json:"visibility"`
+	Private       bool      `json:"private"`
+	Fork          bool      `json:"fork"`
+	Archived      bool      `json:"archived"`
+	Stars         int       `json:"stargazers_count"`
+	CreatedAt     time.Time `json:"created_at"`
+	PushedAt      time.Time `json:"pushed_at"`
+	Owner         struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+}
+
+// organization is the subset of the GitHub organization model used here.
+type organization struct {
+	Login string `json:"login"`
+}
+
+// NewProvider creates a GitHub provider for the account owning cfg.Token.
+// cfg.APIURL selects a GitHub Enterprise Server instance and cfg.GitHost
+// overrides the host of the clone URLs it returns.
+func NewProvider(cfg types.ProviderConfig) (provider.Provider, error) {
+	name := provider.InstanceName(cfg)
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("provider %q: GitHub token is empty", name)
+	}
+
+	apiURL := DefaultAPIURL
+	if cfg.APIURL != "" {
+		apiURL = strings.TrimSuffix(cfg.APIURL, "/")
+	}
+	return &githubProvider{name: name, apiURL: apiURL, gitHost: cfg.GitHost, token: cfg.Token, maxPages: cfg.MaxPages}, nil
+}
+
+func (p *githubProvider) Name() string {
+	return p.name
+}
+
+func (p *githubProvider) ListRepos(ctx context.Context) ([]types.Repo, error) {
+	return FetchRepos(ctx, p.apiURL, p.token, p.maxPages, p.pages)
 }
 
+// SetPageCache makes ListRepos revalidate the pages of its listings with
+// If-None-Match; GitHub does not count 304 responses against the rate limit.
+func (p *githubProvider) SetPageCache(pages *httputil.PageCache) {
+	p.pages = pages
+}
+
+// CloneURL returns the SSH URL of r, on the configured git host if any;
+// GitHub repositories are cloned over SSH.
+func (p *githubProvider) CloneURL(r types.Repo) string {
+	return provider.RewriteHost(r.SSHURL, p.gitHost)
+}
+
+func (p *githubProvider) AuthMethod() provider.AuthMethod {
+	return provider.AuthSSH
+}
+
+// NewClient returns the client used for GitHub API requests, which retries
+// failed requests and waits for the rate limit to reset.
+func NewClient() *httputil.Client {
+	return httputil.NewClient("GitHub", nil)
+}
+
+// Headers returns the request headers required to authenticate against the
+// GitHub REST API with a personal access token.
 func Headers(token string) map[string]string {
 	return map[string]string{
 		"Authorization": "token " + token,
@@ -18,22 +118,182 @@ func Headers(token string) map[string]string {
 	}
 }
 
-func FetchRepos(ctx context.Context, token string) []types.Repo {
+// FetchRepos lists the repositories of the authenticated user and of every
+// organization the user belongs to. Every listing follows the rel="next"
+// links of the Link header, and a summary of the pages and repositories
+// fetched is printed per owner. With a page cache, every page is requested
+// conditionally and served from the cache when it did not change.
+//
+// Parameters:
+//   - ctx: Context for the requests
+//   - apiURL: Root of the GitHub REST API, usually DefaultAPIURL
+//   - token: GitHub personal access token
+//   - maxPages: Maximum number of pages fetched per listing; 0 means no limit
+//   - pageCache: The responses of the previous listing, or nil to request every page in full
+//
+// Returns:
+//   - []types.Repo: The repositories found
+//   - error: Any error that occurred while talking to the GitHub API
+func FetchRepos(ctx context.Context, apiURL string, token string, maxPages int, pageCache *httputil.PageCache) ([]types.Repo, error) {
 	client := NewClient()
+	client.HTTP.Transport = pageCache.Transport(nil)
 	headers := Headers(token)
 
-	userRepos := fetchUserRepos(client, headers)
-	orgRepos := fetchOrgRepos(client, headers)
+	userRepos, err := fetchUserRepos(ctx, client, headers, apiURL, maxPages)
+	if err != nil {
+		return nil, err
+	}
+
+	orgRepos, err := fetchOrgRepos(ctx, client, headers, apiURL, maxPages)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(userRepos, orgRepos...), nil
+}
 
-	return append(userRepos, orgRepos...)
+// pageStats records how much of a paginated listing was fetched.
+type pageStats struct {
+	pages     int
+	truncated bool
 }
 
-func fetchUserRepos(client *http.Client, headers map[string]string) []types.Repo {
-	// Implementation moved from main.go
-	return nil
+func fetchUserRepos(ctx context.Context, client *httputil.Client, headers map[string]string, apiURL string, maxPages int) ([]types.Repo, error) {
+	repos, stats, err := fetchRepoList(ctx, client, headers, apiURL+"/user/repos?per_page=100", maxPages)
+	if err != nil {
+		return nil, err
+	}
+	report("authenticated user", len(repos), stats)
+	return repos, nil
 }
 
-func fetchOrgRepos(client *http.Client, headers map[string]string) []types.Repo {
-	// Implementation moved from main.go
-	return nil
-} 
\ No newline at end of file
+func fetchOrgRepos(ctx context.Context, client *httputil.Client, headers map[string]string, apiURL string, maxPages int) ([]types.Repo, error) {
+	orgs, err := fetchOrganizations(ctx, client, headers, apiURL, maxPages)
+	if err != nil {
+		return nil, err
+	}
+
+	var orgRepos []types.Repo
+	for _, org := range orgs {
+		if org.Login == "" {
+			continue
+		}
+		repos, stats, err := fetchRepoList(ctx, client, headers, fmt.Sprintf("%s/orgs/%s/repos?per_page=100", apiURL, org.Login), maxPages)
+		if err != nil {
+			return nil, err
+		}
+		report(org.Login, len(repos), stats)
+		orgRepos = append(orgRepos, repos...)
+	}
+	return orgRepos, nil
+}
+
+func fetchOrganizations(ctx context.Context, client *httputil.Client, headers map[string]string, apiURL string, maxPages int) ([]organization, error) {
+	orgs, stats, err := fetchAll[organization](ctx, client, headers, apiURL+"/user/orgs?per_page=100", maxPages)
+	if err != nil {
+		return nil, err
+	}
+	if stats.truncated {
+		fmt.Fprintf(os.Stderr, "Warning: GitHub organization listing stopped after %d page(s)\n", stats.pages)
+	}
+	return orgs, nil
+}
+
+func fetchRepoList(ctx context.Context, client *httputil.Client, headers map[string]string, url string, maxPages int) ([]types.Repo, pageStats, error) {
+	data, stats, err := fetchAll[repository](ctx, client, headers, url, maxPages)
+	if err != nil {
+		return nil, stats, err
+	}
+
+	var repos []types.Repo
+	for _, r := range data {
+		repos = append(repos, toRepo(r))
+	}
+	return repos, stats, nil
+}
+
+// toRepo converts a repository of the GitHub REST API.
+func toRepo(r repository) types.Repo {
+	repo := types.Repo{
+		Name:          r.Name,
+		SSHURL:        r.SSHURL,
+		DefaultBranch: r.DefaultBranch,
+		Owner:         r.Owner.Login,
+		FullPath:      r.FullName,
+		HTTPSURL:      r.CloneURL,
+		WebURL:        r.HTMLURL,
+		Description:   r.Description,
+		Language:      r.Language,
+		Topics:        r.Topics,
+		Visibility:    r.Visibility,
+		Fork:          r.Fork,
+		Archived:      r.Archived,
+		Stars:         r.Stars,
+		CreatedAt:     r.CreatedAt,
+		PushedAt:      r.PushedAt,
+	}
+	if r.ID != 0 {
+		repo.ID = strconv.FormatInt(r.ID, 10)
+	}
+	// Older GitHub Enterprise versions only report the private flag
+	if repo.Visibility == "" {
+		repo.Visibility = "public"
+		if r.Private {
+			repo.Visibility = "private"
+		}
+	}
+	return repo
+}
+
+// fetchAll requests url and every page linked from it as rel="next",
+// stopping once maxPages pages were read when maxPages is positive.
+func fetchAll[T any](ctx context.Context, client *httputil.Client, headers map[string]string, url string, maxPages int) ([]T, pageStats, error) {
+	var items []T
+	var stats pageStats
+	for url != "" {
+		if maxPages > 0 && stats.pages == maxPages {
+			stats.truncated = true
+			break
+		}
+
+		var page []T
+		next, err := getJSON(ctx, client, headers, url, &page)
+		if err != nil {
+			return nil, stats, err
+		}
+		items = append(items, page...)
+		stats.pages++
+		url = next
+	}
+	return items, stats, nil
+}
+
+func report(owner string, repos int, stats pageStats) {
+	fmt.Fprintf(os.Stderr, "GitHub: fetched %d repos in %d page(s) for %s\n", repos, stats.pages, owner)
+	if stats.truncated {
+		fmt.Fprintf(os.Stderr, "Warning: GitHub listing for %s stopped at the %d page limit, more repositories are available\n", owner, stats.pages)
+	}
+}
+
+// getJSON decodes the response of a GET request for url into v and returns
+// the URL of the next page, or an empty string on the last page.
+func getJSON(ctx context.Context, client *httputil.Client, headers map[string]string, url string, v interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	for k, val := range headers {
+		req.Header.Add(k, val)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", fmt.Errorf("error decoding GitHub response (URL: %s): %w", url, err)
+	}
+	return httputil.NextLink(resp.Header.Get("Link")), nil
+}
@@ -0,0 +1,307 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"christopherharwell/project_monorepo/pkg/httputil"
+	"christopherharwell/project_monorepo/pkg/provider"
+	"christopherharwell/project_monorepo/pkg/types"
+)
+
+func TestFetchRepoList(t *testing.T) {
+	// Setup mock GitHub server
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{
+			"name": "test-repo",
+			"ssh_url": "git@github.com:test/test-repo.git",
+			"default_branch": "main"
+		}]`))
+	}))
+	defer ts.Close()
+
+	repos, _, err := fetchRepoList(context.Background(), NewClient(), Headers("test-token"), ts.URL, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 1 {
+		t.Fatalf("Expected 1 repo, got %d", len(repos))
+	}
+	if repos[0].Name != "test-repo" {
+		t.Errorf("Expected repo name 'test-repo', got '%s'", repos[0].Name)
+	}
+}
+
+func TestFetchRepos(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token test-token" {
+			t.Errorf("Unexpected Authorization header: %q", r.Header.Get("Authorization"))
+		}
+		switch r.URL.Path {
+		case "/user/repos":
+			w.Write([]byte(`[{"name": "user-repo"}]`))
+		case "/user/orgs":
+			w.Write([]byte(`[{"login": "test-org"}]`))
+		case "/orgs/test-org/repos":
+			w.Write([]byte(`[{"name": "org-repo", "ssh_url": "git@github.com:test-org/org-repo.git", "default_branch": null}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	repos, err := FetchRepos(context.Background(), ts.URL, "test-token", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 {
+		t.Fatalf("Expected 2 repos, got %d", len(repos))
+	}
+	if repos[1].Name != "org-repo" {
+		t.Errorf("Expected org repo last, got '%s'", repos[1].Name)
+	}
+}
+
+func TestFetchReposError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"message": "Bad credentials"}`))
+	}))
+	defer ts.Close()
+
+	repos, err := FetchRepos(context.Background(), ts.URL, "bad-token", 0, nil)
+	if err == nil {
+		t.Fatal("Expected an error for an unauthorized request")
+	}
+	if repos != nil {
+		t.Errorf("Expected no repos, got %d", len(repos))
+	}
+	var apiErr *httputil.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a 401 *httputil.APIError, got %v", err)
+	}
+}
+
+// pagedServer serves total repositories for /user/repos in pages of size
+// perPage, linking the pages with a GitHub style Link header.
+func pagedServer(total, perPage int) *httptest.Server {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/user/orgs":
+			w.Write([]byte(`[]`))
+			return
+		case "/user/repos":
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		page := 1
+		fmt.Sscanf(r.URL.Query().Get("page"), "%d", &page)
+		lastPage := (total + perPage - 1) / perPage
+		if page < lastPage {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/user/repos?per_page=100&page=%d>; rel="next", <%s/user/repos?per_page=100&page=%d>; rel="last"`, ts.URL, page+1, ts.URL, lastPage))
+		}
+
+		w.Write([]byte("["))
+		for i := (page - 1) * perPage; i < page*perPage && i < total; i++ {
+			if i > (page-1)*perPage {
+				w.Write([]byte(","))
+			}
+			fmt.Fprintf(w, `{"name": "repo-%d", "ssh_url": "git@github.com:test/repo-%d.git", "default_branch": "main"}`, i, i)
+		}
+		w.Write([]byte("]"))
+	}))
+	return ts
+}
+
+func TestFetchReposFollowsLinkHeader(t *testing.T) {
+	ts := pagedServer(250, 100)
+	defer ts.Close()
+
+	repos, err := FetchRepos(context.Background(), ts.URL, "test-token", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 250 {
+		t.Fatalf("Expected 250 repos, got %d", len(repos))
+	}
+	if repos[249].Name != "repo-249" {
+		t.Errorf("Expected last repo 'repo-249', got '%s'", repos[249].Name)
+	}
+}
+
+func TestFetchAllMaxPages(t *testing.T) {
+	ts := pagedServer(250, 100)
+	defer ts.Close()
+
+	repos, stats, err := fetchRepoList(context.Background(), NewClient(), Headers("test-token"), ts.URL+"/user/repos?per_page=100", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 200 {
+		t.Errorf("Expected 200 repos, got %d", len(repos))
+	}
+	if stats.pages != 2 || !stats.truncated {
+		t.Errorf("Expected 2 truncated pages, got %+v", stats)
+	}
+}
+
+func TestEnterpriseInstances(t *testing.T) {
+	newServer := func(repo string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v3/user/repos":
+				fmt.Fprintf(w, `[{"name": %q, "ssh_url": "git@github.internal:team/%s.git", "default_branch": "main"}]`, repo, repo)
+			case "/api/v3/user/orgs":
+				w.Write([]byte(`[]`))
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+	}
+	enterprise := newServer("enterprise-repo")
+	defer enterprise.Close()
+	other := newServer("other-repo")
+	defer other.Close()
+
+	providers, err := provider.NewAll([]types.ProviderConfig{
+		{Type: "github", Name: "ghe", Token: "test-token", APIURL: enterprise.URL + "/api/v3/", GitHost: "ssh.github.example.com"},
+		{Type: "github", Name: "ghe-other", Token: "test-token", APIURL: other.URL + "/api/v3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repos, err := provider.FetchAll(context.Background(), providers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 {
+		t.Fatalf("Expected 2 repos, got %d", len(repos))
+	}
+	if repos[0].Name != "enterprise-repo" || repos[0].Provider != "ghe" {
+		t.Errorf("Unexpected first repo: %+v", repos[0])
+	}
+	if got := provider.CloneURL(providers, repos[0]); got != "git@ssh.github.example.com:team/enterprise-repo.git" {
+		t.Errorf("Unexpected clone URL: %s", got)
+	}
+	if got := provider.CloneURL(providers, repos[1]); got != "git@github.internal:team/other-repo.git" {
+		t.Errorf("Unexpected clone URL: %s", got)
+	}
+}
+
+func TestToRepoMetadata(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{
+			"id": 1296269,
+			"name": "portfolio",
+			"full_name": "octo-org/portfolio",
+			"owner": {"login": "octo-org", "type": "Organization"},
+			"private": false,
+			"visibility": "internal",
+			"html_url": "https://github.com/octo-org/portfolio",
+			"description": "Personal site",
+			"fork": true,
+			"ssh_url": "git@github.com:octo-org/portfolio.git",
+			"clone_url": "https://github.com/octo-org/portfolio.git",
+			"language": "TypeScript",
+			"topics": ["website", "portfolio"],
+			"archived": true,
+			"stargazers_count": 42,
+			"default_branch": "main",
+			"created_at": "2021-03-04T05:06:07Z",
+			"pushed_at": "2024-12-01T08:09:10Z"
+		}, {
+			"name": "empty",
+			"private": true,
+			"description": null,
+			"language": null,
+			"default_branch": null
+		}]`))
+	}))
+	defer ts.Close()
+
+	repos, _, err := fetchRepoList(context.Background(), NewClient(), Headers("test-token"), ts.URL, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 {
+		t.Fatalf("Expected 2 repos, got %d", len(repos))
+	}
+
+	want := types.Repo{
+		Name:          "portfolio",
+		ID:            "1296269",
+		SSHURL:        "git@github.com:octo-org/portfolio.git",
+		DefaultBranch: "main",
+		Owner:         "octo-org",
+		FullPath:      "octo-org/portfolio",
+		HTTPSURL:      "https://github.com/octo-org/portfolio.git",
+		WebURL:        "https://github.com/octo-org/portfolio",
+		Description:   "Personal site",
+		Language:      "TypeScript",
+		Topics:        []string{"website", "portfolio"},
+		Visibility:    "internal",
+		Fork:          true,
+		Archived:      true,
+		Stars:         42,
+		CreatedAt:     time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+		PushedAt:      time.Date(2024, 12, 1, 8, 9, 10, 0, time.UTC),
+	}
+	if !reflect.DeepEqual(repos[0], want) {
+		t.Errorf("Expected %+v, got %+v", want, repos[0])
+	}
+	if repos[1].Visibility != "private" || repos[1].DefaultBranch != "" {
+		t.Errorf("Unexpected metadata for empty repo: %+v", repos[1])
+	}
+}
+
+func TestFetchReposConditional(t *testing.T) {
+	notModified := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `W/"` + r.URL.Path + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		switch r.URL.Path {
+		case "/user/repos":
+			w.Write([]byte(`[{"id": 1, "name": "user-repo"}]`))
+		case "/user/orgs":
+			w.Write([]byte(`[{"login": "test-org"}]`))
+		case "/orgs/test-org/repos":
+			w.Write([]byte(`[{"id": 2, "name": "org-repo"}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	first := httputil.NewPageCache(nil)
+	want, err := FetchRepos(context.Background(), ts.URL, "test-token", 0, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second := httputil.NewPageCache(first.Pages())
+	repos, err := FetchRepos(context.Background(), ts.URL, "test-token", 0, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(repos, want) {
+		t.Errorf("Expected %+v from the cached pages, got %+v", want, repos)
+	}
+	if requests, cached := second.Stats(); requests != 3 || cached != 3 || notModified != 3 {
+		t.Errorf("Expected 3 of 3 requests served from cache, got %d of %d (server: %d)", cached, requests, notModified)
+	}
+}
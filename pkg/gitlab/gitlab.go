@@ -1,35 +1,267 @@
 package gitlab
 
 import (
+	"christopherharwell/project_monorepo/pkg/httputil"
+	"christopherharwell/project_monorepo/pkg/provider"
 	"christopherharwell/project_monorepo/pkg/types"
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
-const gitlabAPIURL = "https://gitlab.com/api/v4"
+// DefaultAPIURL is the REST API root of gitlab.com. Self-managed instances
+// serve the API at https://<host>/api/v4.
+const DefaultAPIURL = "https://gitlab.com/api/v4"
 
-func FetchRepos(ctx context.Context, token string) []types.Repo {
-	req := createRequest(token)
-	resp, err := executeRequest(req)
+func init() {
+	provider.Register("gitlab", NewProvider)
+}
+
+// gitlabProvider lists the projects a GitLab user is a member of.
+type gitlabProvider struct {
+	name     string
+	apiURL   string
+	gitHost  string
+	token    string
+	maxPages int
+	pages    *httputil.PageCache
+	keyset   bool
+}
+
+// project is the subset of the GitLab project model used here. Fields that
+// are null, such as the default branch of an empty project, are left empty.
+type project struct {
+	ID                int64     `json:"id"`
+	Name              string    `json:"name"`
+	PathWithNamespace string    `json:"path_with_namespace"`
+	HTTPURLToRepo     string    `json:"http_url_to_repo"`
+	WebURL            string    `json:"web_url"`
+	DefaultBranch     string    `json:"default_branch"`
+	Description       string    `json:"description"`
+	Visibility        string    `json:"visibility"`
+	Topics            []string  `json:"topics"`
+	TagList           []string  `json:"tag_list"`
+	Archived          bool      `json:"archived"`
+	Stars             int       `json:"star_count"`
+	CreatedAt         time.Time `json:"created_at"`
+	LastActivityAt    time.Time `json:"last_activity_at"`
+	ForkedFromProject *struct {
+		ID int64 `json:"id"`
+	} `json:"forked_from_project"`
+	Namespace struct {
+		FullPath string `json:"full_path"`
+	} `json:"namespace"`
+}
+
+// NewProvider creates a GitLab provider for the user owning cfg.Token.
+// cfg.APIURL selects a self-managed instance and cfg.GitHost overrides the
+// host of the clone URLs it returns.
+func NewProvider(cfg types.ProviderConfig) (provider.Provider, error) {
+	name := provider.InstanceName(cfg)
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("provider %q: GitLab token is empty", name)
+	}
+
+	apiURL := DefaultAPIURL
+	if cfg.APIURL != "" {
+		apiURL = strings.TrimSuffix(cfg.APIURL, "/")
+	}
+	return &gitlabProvider{
+		name:     name,
+		apiURL:   apiURL,
+		gitHost:  cfg.GitHost,
+		token:    cfg.Token,
+		maxPages: cfg.MaxPages,
+		keyset:   cfg.KeysetPagination,
+	}, nil
+}
+
+func (p *gitlabProvider) Name() string {
+	return p.name
+}
+
+func (p *gitlabProvider) ListRepos(ctx context.Context) ([]types.Repo, error) {
+	return FetchRepos(ctx, p.apiURL, p.token, p.maxPages, p.keyset, p.pages)
+}
+
+// SetPageCache makes ListRepos revalidate the pages of its listing with
+// If-None-Match.
+func (p *gitlabProvider) SetPageCache(pages *httputil.PageCache) {
+	p.pages = pages
+}
+
+// CloneURL returns the HTTPS URL of r, on the configured git host if any,
+// with the token embedded for authentication.
+func (p *gitlabProvider) CloneURL(r types.Repo) string {
+	return authenticatedURL(provider.RewriteHost(r.SSHURL, p.gitHost), p.token)
+}
+
+func (p *gitlabProvider) AuthMethod() provider.AuthMethod {
+	return provider.AuthToken
+}
+
+// FetchRepos lists every project the authenticated user is a member of,
+// walking all pages of the listing. GitLab projects are cloned over HTTPS, so
+// the returned SSHURL holds the plain HTTPS URL; the provider's CloneURL adds
+// the token.
+//
+// Offset pagination follows the X-Next-Page header. Keyset pagination, which
+// large instances require beyond 50,000 projects, follows the rel="next" Link
+// header instead. With a page cache, every page is requested conditionally
+// and served from the cache when it did not change.
+//
+// Parameters:
+//   - ctx: Context for the requests
+//   - apiURL: Root of the GitLab REST API, usually DefaultAPIURL
+//   - token: GitLab personal access token
+//   - maxPages: Maximum number of pages fetched; 0 means no limit
+//   - keyset: Whether to request keyset pagination instead of offset pagination
+//   - pageCache: The responses of the previous listing, or nil to request every page in full
+//
+// Returns:
+//   - []types.Repo: The projects found
+//   - error: Any error that occurred while talking to the GitLab API
+func FetchRepos(ctx context.Context, apiURL string, token string, maxPages int, keyset bool, pageCache *httputil.PageCache) ([]types.Repo, error) {
+	client := httputil.NewClient("GitLab", pageCache.Transport(nil))
+
+	var repos []types.Repo
+	pages := 0
+	url := projectsURL(apiURL, keyset)
+	for url != "" {
+		if maxPages > 0 && pages == maxPages {
+			fmt.Fprintf(os.Stderr, "Warning: GitLab listing stopped at the %d page limit, more projects are available\n", pages)
+			break
+		}
+
+		req, err := createRequest(ctx, url, token)
+		if err != nil {
+			return nil, err
+		}
+
+		page, next, err := fetchPage(client, req)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, page...)
+		pages++
+		url = next
+	}
+
+	fmt.Fprintf(os.Stderr, "GitLab: fetched %d projects in %d page(s)\n", len(repos), pages)
+	return repos, nil
+}
+
+func projectsURL(apiURL string, keyset bool) string {
+	url := apiURL + "/projects?membership=true&per_page=100"
+	if keyset {
+		url += "&pagination=keyset&order_by=id&sort=asc"
+	}
+	return url
+}
+
+func createRequest(ctx context.Context, url string, token string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil
+		return nil, err
+	}
+	req.Header.Add("PRIVATE-TOKEN", token)
+	return req, nil
+}
+
+func fetchPage(client *httputil.Client, req *http.Request) ([]types.Repo, string, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
 	}
 	defer resp.Body.Close()
 
-	return parseResponse(resp, token)
+	repos, err := parseResponse(resp)
+	if err != nil {
+		return nil, "", err
+	}
+	return repos, nextPageURL(resp), nil
+}
+
+// nextPageURL returns the URL of the page following resp, or an empty string
+// on the last page. Keyset responses carry a Link header; offset responses
+// carry the next page number in X-Next-Page.
+func nextPageURL(resp *http.Response) string {
+	if next := httputil.NextLink(resp.Header.Get("Link")); next != "" {
+		return next
+	}
+
+	nextPage := strings.TrimSpace(resp.Header.Get("X-Next-Page"))
+	if nextPage == "" {
+		return ""
+	}
+	u := *resp.Request.URL
+	query := u.Query()
+	query.Set("page", nextPage)
+	u.RawQuery = query.Encode()
+	return u.String()
 }
 
-func createRequest(token string) *http.Request {
-	// Implementation moved from main.go
-	return nil
+func parseResponse(resp *http.Response) ([]types.Repo, error) {
+	var data []project
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("error decoding GitLab response: %w", err)
+	}
+
+	return processRepos(data), nil
 }
 
-func executeRequest(req *http.Request) (*http.Response, error) {
-	// Implementation moved from main.go
-	return nil, nil
+func processRepos(data []project) []types.Repo {
+	var repos []types.Repo
+	for _, r := range data {
+		if r.HTTPURLToRepo == "" {
+			fmt.Fprintf(os.Stderr, "Warning: Could not get HTTP URL for repo %s\n", r.Name)
+			continue
+		}
+		repos = append(repos, toRepo(r))
+	}
+	return repos
 }
 
-func parseResponse(resp *http.Response, token string) []types.Repo {
-	// Implementation moved from main.go
-	return nil
-} 
\ No newline at end of file
+// toRepo converts a project of the GitLab REST API. GitLab projects are
+// cloned over HTTPS, so SSHURL holds the HTTPS URL as well.
+func toRepo(r project) types.Repo {
+	repo := types.Repo{
+		Name:          r.Name,
+		SSHURL:        r.HTTPURLToRepo,
+		DefaultBranch: r.DefaultBranch,
+		Owner:         r.Namespace.FullPath,
+		FullPath:      r.PathWithNamespace,
+		HTTPSURL:      r.HTTPURLToRepo,
+		WebURL:        r.WebURL,
+		Description:   r.Description,
+		Topics:        r.Topics,
+		Visibility:    r.Visibility,
+		Fork:          r.ForkedFromProject != nil,
+		Archived:      r.Archived,
+		Stars:         r.Stars,
+		CreatedAt:     r.CreatedAt,
+		PushedAt:      r.LastActivityAt,
+	}
+	if r.ID != 0 {
+		repo.ID = strconv.FormatInt(r.ID, 10)
+	}
+	// Older GitLab versions report topics as tag_list
+	if repo.Topics == nil {
+		repo.Topics = r.TagList
+	}
+	return repo
+}
+
+// authenticatedURL adds the token to an HTTPS clone URL for authentication.
+// URLs that already carry credentials are returned unchanged.
+func authenticatedURL(httpURL, token string) string {
+	if strings.Contains(httpURL, "@") {
+		return httpURL
+	}
+	return strings.Replace(httpURL, "https://", fmt.Sprintf("https://oauth2:%s@", token), 1)
+}
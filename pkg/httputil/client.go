@@ -0,0 +1,351 @@
+package httputil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTimeout bounds a single attempt of a request
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultMaxRetries is how often a failed request is retried
+	DefaultMaxRetries = 3
+
+	// DefaultBackoff is the delay before the first retry
+	DefaultBackoff = time.Second
+
+	// DefaultMaxWait is the longest wait for an exhausted rate limit to reset
+	DefaultMaxWait = 2 * time.Minute
+
+	// maxBackoff caps the delay between two retries
+	maxBackoff = 30 * time.Second
+)
+
+// APIError is returned for a request that failed, either with a response
+// other than 2xx or, when StatusCode is zero, without a response at all.
+type APIError struct {
+	// Service names the API, e.g. "GitHub"
+	Service string
+
+	// URL is the requested URL
+	URL string
+
+	// StatusCode is the status of the response, or 0 when there was none
+	StatusCode int
+
+	// Body is the beginning of the response body
+	Body string
+
+	// Err is the network error when there was no response
+	Err error
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("%s API request failed (URL: %s): %v", e.Service, e.URL, e.Err)
+	}
+	return fmt.Sprintf("%s API error (URL: %s, Status: %d): %s", e.Service, e.URL, e.StatusCode, e.Body)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// NewAPIError reads the body of resp, closes it and returns the error for
+// the failed request.
+func NewAPIError(service string, resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	return &APIError{
+		Service:    service,
+		URL:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+		Body:       strings.TrimSpace(string(body)),
+	}
+}
+
+// RateLimitError is returned when the rate limit of an API is exhausted and
+// does not reset within the client's MaxWait.
+type RateLimitError struct {
+	// Service names the API, e.g. "GitHub"
+	Service string
+
+	// URL is the requested URL
+	URL string
+
+	// Reset is when the rate limit resets, or the zero time when unknown
+	Reset time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Reset.IsZero() {
+		return fmt.Sprintf("%s API rate limit exhausted (URL: %s)", e.Service, e.URL)
+	}
+	return fmt.Sprintf("%s API rate limit exhausted (URL: %s), it resets at %s", e.Service, e.URL, e.Reset.Local().Format(time.DateTime))
+}
+
+// Client sends API requests for a provider. It retries network errors and
+// 5xx responses with jittered exponential backoff, waits for exhausted rate
+// limits to reset as reported by the Retry-After and X-RateLimit-Remaining /
+// X-RateLimit-Reset headers (or their RateLimit- variants), and returns an
+// *APIError or *RateLimitError instead of a failed response.
+type Client struct {
+	// Service names the API in messages and errors, e.g. "GitHub"
+	Service string
+
+	// HTTP sends the requests
+	HTTP *http.Client
+
+	// MaxRetries is how often a failed request is retried
+	MaxRetries int
+
+	// Backoff is the delay before the first retry; it doubles with every retry
+	Backoff time.Duration
+
+	// MaxWait is the longest wait for a rate limit to reset before giving up
+	MaxWait time.Duration
+
+	mu           sync.Mutex
+	blockedUntil time.Time
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewClient returns a client for service with the default retry settings,
+// sending its requests through transport; nil means http.DefaultTransport.
+func NewClient(service string, transport http.RoundTripper) *Client {
+	return &Client{
+		Service:    service,
+		HTTP:       &http.Client{Timeout: DefaultTimeout, Transport: transport},
+		MaxRetries: DefaultMaxRetries,
+		Backoff:    DefaultBackoff,
+		MaxWait:    DefaultMaxWait,
+	}
+}
+
+// Do sends req and returns its 2xx response. Requests with a body are not
+// retried, as it cannot be sent twice.
+//
+// Parameters:
+//   - req: The request, whose context bounds all attempts and waits
+//
+// Returns:
+//   - *http.Response: The successful response; the caller closes its body
+//   - error: An *APIError, a *RateLimitError, or the error of the canceled context
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	url := req.URL.String()
+	retries := c.MaxRetries
+	if req.Body != nil && req.Body != http.NoBody {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := c.waitForReset(ctx, url); err != nil {
+			return nil, err
+		}
+
+		resp, err := c.HTTP.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("%s API request canceled (URL: %s): %w", c.Service, url, ctx.Err())
+			}
+			apiErr := &APIError{Service: c.Service, URL: url, Err: err}
+			if attempt >= retries {
+				return nil, apiErr
+			}
+			if err := c.retry(ctx, attempt, retries, c.backoff(attempt), err.Error()); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		c.noteRateLimit(resp)
+
+		if (resp.StatusCode >= 200 && resp.StatusCode < 300) || resp.StatusCode == http.StatusNotModified {
+			return resp, nil
+		}
+
+		if rateLimited(resp) {
+			wait, known := resetWait(resp.Header, c.clock())
+			if !known {
+				wait = c.backoff(attempt)
+			}
+			discard(resp)
+			if attempt >= retries || wait > c.MaxWait {
+				rlErr := &RateLimitError{Service: c.Service, URL: url}
+				if known {
+					rlErr.Reset = c.clock().Add(wait)
+				}
+				return nil, rlErr
+			}
+			fmt.Fprintf(os.Stderr, "%s: rate limit exhausted, waiting %s for it to reset\n", c.Service, wait.Round(time.Second))
+			if err := c.wait(ctx, wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if resp.StatusCode < 500 || attempt >= retries {
+			return nil, NewAPIError(c.Service, resp)
+		}
+		delay, known := resetWait(resp.Header, c.clock())
+		if !known || delay > c.MaxWait {
+			delay = c.backoff(attempt)
+		}
+		discard(resp)
+		if err := c.retry(ctx, attempt, retries, delay, resp.Status); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retry reports the failed attempt and waits delay before the next one.
+func (c *Client) retry(ctx context.Context, attempt int, retries int, delay time.Duration, reason string) error {
+	fmt.Fprintf(os.Stderr, "%s: %s, retrying in %s (%d/%d)\n", c.Service, reason, delay.Round(time.Millisecond), attempt+1, retries)
+	return c.wait(ctx, delay)
+}
+
+func (c *Client) wait(ctx context.Context, d time.Duration) error {
+	pause := c.sleep
+	if pause == nil {
+		pause = sleep
+	}
+	if err := pause(ctx, d); err != nil {
+		return fmt.Errorf("%s API request canceled: %w", c.Service, err)
+	}
+	return nil
+}
+
+func (c *Client) clock() time.Time {
+	if c.now == nil {
+		return time.Now()
+	}
+	return c.now()
+}
+
+// backoff returns the delay before retry number attempt+1: Backoff doubled
+// for every earlier retry, capped, with the upper half chosen at random so
+// concurrent clients do not retry in lockstep.
+func (c *Client) backoff(attempt int) time.Duration {
+	base := c.Backoff
+	if base <= 0 {
+		base = DefaultBackoff
+	}
+	d := base << min(attempt, 16)
+	if d <= 0 || d > maxBackoff {
+		d = maxBackoff
+	}
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int64N(half+1))
+}
+
+// noteRateLimit remembers when the rate limit resets once resp reports it
+// exhausted, so the next request waits instead of being refused.
+func (c *Client) noteRateLimit(resp *http.Response) {
+	if rateLimitHeader(resp.Header, "Remaining") != "0" {
+		return
+	}
+	reset, ok := resetTime(resp.Header, c.clock())
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blockedUntil = reset
+}
+
+// waitForReset waits until a rate limit reported exhausted resets, or fails
+// when that takes longer than MaxWait.
+func (c *Client) waitForReset(ctx context.Context, url string) error {
+	c.mu.Lock()
+	until := c.blockedUntil
+	c.blockedUntil = time.Time{}
+	c.mu.Unlock()
+
+	wait := until.Sub(c.clock())
+	if until.IsZero() || wait <= 0 {
+		return nil
+	}
+	if wait > c.MaxWait {
+		return &RateLimitError{Service: c.Service, URL: url, Reset: until}
+	}
+	fmt.Fprintf(os.Stderr, "%s: rate limit exhausted, waiting %s for it to reset\n", c.Service, wait.Round(time.Second))
+	return c.wait(ctx, wait)
+}
+
+// rateLimited reports whether resp refused the request because of a rate
+// limit. GitHub answers 403 rather than 429 for exhausted limits.
+func rateLimited(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		return rateLimitHeader(resp.Header, "Remaining") == "0" || resp.Header.Get("Retry-After") != ""
+	}
+	return false
+}
+
+// resetWait returns how long the server asks to wait, from Retry-After or
+// else the rate limit reset time.
+func resetWait(h http.Header, now time.Time) (time.Duration, bool) {
+	if v := strings.TrimSpace(h.Get("Retry-After")); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil {
+			return time.Duration(max(secs, 0)) * time.Second, true
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return max(t.Sub(now), 0), true
+		}
+	}
+	if reset, ok := resetTime(h, now); ok {
+		return max(reset.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// resetTime returns when the rate limit resets. The reset header holds Unix
+// seconds on GitHub and GitLab; small values are taken as seconds from now.
+func resetTime(h http.Header, now time.Time) (time.Time, bool) {
+	secs, err := strconv.ParseInt(strings.TrimSpace(rateLimitHeader(h, "Reset")), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if secs < 1_000_000_000 {
+		return now.Add(time.Duration(secs) * time.Second), true
+	}
+	return time.Unix(secs, 0), true
+}
+
+func rateLimitHeader(h http.Header, name string) string {
+	if v := h.Get("X-RateLimit-" + name); v != "" {
+		return v
+	}
+	return h.Get("RateLimit-" + name)
+}
+
+// discard drains and closes the body of a response that is not used, so the
+// connection can be reused.
+func discard(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
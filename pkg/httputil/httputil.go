@@ -0,0 +1,22 @@
+package httputil
+
+import "strings"
+
+// NextLink extracts the rel="next" target from an RFC 8288 Link header such as
+// `<https://api.github.com/user/repos?page=2>; rel="next", <...>; rel="last"`.
+// It returns an empty string when there is no next page.
+func NextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		target := strings.TrimSpace(parts[0])
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+		for _, param := range parts[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(target, "<>")
+			}
+		}
+	}
+	return ""
+}
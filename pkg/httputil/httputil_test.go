@@ -0,0 +1,226 @@
+package httputil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNextLink(t *testing.T) {
+	tests := map[string]string{
+		"": "",
+		`<https://api.github.com/user/repos?page=2>; rel="next", <https://api.github.com/user/repos?page=5>; rel="last"`:  "https://api.github.com/user/repos?page=2",
+		`<https://api.github.com/user/repos?page=1>; rel="prev", <https://api.github.com/user/repos?page=1>; rel="first"`: "",
+		`<https://gitlab.com/api/v4/projects?id_after=42&pagination=keyset>; rel="next"`:                                  "https://gitlab.com/api/v4/projects?id_after=42&pagination=keyset",
+	}
+	for header, want := range tests {
+		if got := NextLink(header); got != want {
+			t.Errorf("NextLink(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestPageCache(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		etag := `"v1-` + r.URL.Path + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Link", `<`+r.URL.Path+`?page=2>; rel="next"`)
+		fmt.Fprintf(w, `[{"path": %q}]`, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	get := func(c *PageCache, path string) (string, string) {
+		client := &http.Client{Transport: c.Transport(nil)}
+		resp, err := client.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body), resp.Header.Get("Link")
+	}
+
+	first := NewPageCache(nil)
+	get(first, "/a")
+	get(first, "/b")
+	if requests, notModified := first.Stats(); requests != 2 || notModified != 0 {
+		t.Errorf("Stats = %d, %d, want 2, 0", requests, notModified)
+	}
+
+	second := NewPageCache(first.Pages())
+	body, link := get(second, "/a")
+	if body != `[{"path": "/a"}]` || link != `</a?page=2>; rel="next"` {
+		t.Errorf("Cached response = %q with Link %q", body, link)
+	}
+	if requests, notModified := second.Stats(); requests != 1 || notModified != 1 {
+		t.Errorf("Stats = %d, %d, want 1, 1", requests, notModified)
+	}
+	if pages := second.Pages(); len(pages) != 1 || pages[ts.URL+"/a"].ETag != `"v1-/a"` {
+		t.Errorf("Pages = %+v, want only /a", pages)
+	}
+	if requests != 3 {
+		t.Errorf("Server saw %d requests, want 3", requests)
+	}
+
+	var none *PageCache
+	if body, _ := get(none, "/c"); body != `[{"path": "/c"}]` {
+		t.Errorf("Uncached response = %q", body)
+	}
+}
+
+// testClient returns a client for ts that records its waits instead of sleeping.
+func testClient(waits *[]time.Duration) *Client {
+	c := NewClient("Test", nil)
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return ctx.Err()
+	}
+	return c
+}
+
+func get(t *testing.T, c *Client, ctx context.Context, url string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.Do(req)
+}
+
+func TestClientRetriesServerErrors(t *testing.T) {
+	sent := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent++
+		if sent < 3 {
+			http.Error(w, "unavailable", http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer ts.Close()
+
+	var waits []time.Duration
+	resp, err := get(t, testClient(&waits), context.Background(), ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if sent != 3 || len(waits) != 2 {
+		t.Fatalf("Expected 3 requests and 2 waits, got %d and %v", sent, waits)
+	}
+	if waits[0] < DefaultBackoff/2 || waits[0] > DefaultBackoff || waits[1] < DefaultBackoff || waits[1] > 2*DefaultBackoff {
+		t.Errorf("Unexpected backoff %v", waits)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Unix()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/exhausted":
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", fmt.Sprint(reset))
+			http.Error(w, "API rate limit exceeded", http.StatusForbidden)
+		case "/down":
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	var waits []time.Duration
+	c := testClient(&waits)
+
+	_, err := get(t, c, context.Background(), ts.URL+"/missing")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || len(waits) != 0 {
+		t.Errorf("Expected a 404 *APIError without retries, got %v after %v", err, waits)
+	}
+
+	_, err = get(t, c, context.Background(), ts.URL+"/exhausted")
+	var rlErr *RateLimitError
+	if !errors.As(err, &rlErr) || rlErr.Reset.Unix() != reset || len(waits) != 0 {
+		t.Errorf("Expected a *RateLimitError resetting at %d without waiting, got %v after %v", reset, err, waits)
+	}
+
+	// The exhausted limit holds back every later request of the client
+	if _, err = get(t, c, context.Background(), ts.URL+"/missing"); !errors.As(err, &rlErr) {
+		t.Errorf("Expected a *RateLimitError until the reset, got %v", err)
+	}
+
+	c = testClient(&waits)
+	_, err = get(t, c, context.Background(), ts.URL+"/down")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || len(waits) != DefaultMaxRetries {
+		t.Errorf("Expected a 503 *APIError after %d retries, got %v after %v", DefaultMaxRetries, err, waits)
+	}
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	waits = nil
+	_, err = get(t, c, context.Background(), closed.URL)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 0 || apiErr.Err == nil || len(waits) != DefaultMaxRetries {
+		t.Errorf("Expected a network *APIError after %d retries, got %v after %v", DefaultMaxRetries, err, waits)
+	}
+}
+
+func TestClientWaitsForRateLimit(t *testing.T) {
+	sent := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent++
+		switch sent {
+		case 1:
+			w.Header().Set("Retry-After", "7")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		case 2:
+			// The last request of the window succeeds and reports it exhausted
+			w.Header().Set("RateLimit-Remaining", "0")
+			w.Header().Set("RateLimit-Reset", "30")
+			w.Write([]byte(`[]`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer ts.Close()
+
+	var waits []time.Duration
+	c := testClient(&waits)
+	for range 2 {
+		resp, err := get(t, c, context.Background(), ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if sent != 3 || len(waits) != 2 || waits[0] != 7*time.Second || waits[1] < 29*time.Second || waits[1] > 30*time.Second {
+		t.Errorf("Expected waits of 7s and 30s around 3 requests, got %v and %d requests", waits, sent)
+	}
+}
+
+func TestClientHonorsCancellation(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := NewClient("Test", nil)
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return ctx.Err()
+	}
+	if _, err := get(t, c, ctx, ts.URL); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package httputil
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// keptHeaders are the response headers stored with a cached page, which the
+// listings need to find the next page.
+var keptHeaders = []string{"Content-Type", "Link", "X-Next-Page"}
+
+// Page is a response to a GET request kept for conditional requests.
+type Page struct {
+	// ETag is the entity tag the server sent with the response
+	ETag string `json:"etag,omitempty"`
+
+	// LastModified is the Last-Modified date the server sent with the response
+	LastModified string `json:"last_modified,omitempty"`
+
+	// Header holds the response headers listings depend on, such as Link
+	Header map[string]string `json:"header,omitempty"`
+
+	// Body is the JSON response body
+	Body json.RawMessage `json:"body"`
+}
+
+// PageCache revalidates GET requests with the validators of earlier
+// responses and serves the earlier response when the server answers
+// 304 Not Modified. It counts how many requests were sent and how many of
+// them were served from the cache.
+type PageCache struct {
+	mu       sync.Mutex
+	previous map[string]Page
+	current  map[string]Page
+
+	requests    int
+	notModified int
+}
+
+// NewPageCache returns a cache holding pages, the responses of an earlier
+// run keyed by request URL. pages may be nil.
+func NewPageCache(pages map[string]Page) *PageCache {
+	return &PageCache{previous: pages, current: map[string]Page{}}
+}
+
+// Pages returns the responses that can be revalidated next time: those of
+// the URLs requested through the cache, so pages no longer requested drop out.
+func (c *PageCache) Pages() map[string]Page {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pages := make(map[string]Page, len(c.current))
+	for url, page := range c.current {
+		pages[url] = page
+	}
+	return pages
+}
+
+// Stats returns the number of GET requests sent through the cache and how
+// many of them were answered with 304 Not Modified and served from it.
+func (c *PageCache) Stats() (requests int, notModified int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests, c.notModified
+}
+
+// Transport returns a RoundTripper sending requests through base, nil
+// meaning http.DefaultTransport. A nil cache returns base unchanged, so
+// callers can wrap their transport unconditionally.
+func (c *PageCache) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if c == nil {
+		return base
+	}
+	return &cachingTransport{cache: c, base: base}
+}
+
+func (c *PageCache) lookup(url string) (Page, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	page, ok := c.previous[url]
+	return page, ok
+}
+
+func (c *PageCache) record(url string, page *Page, notModified bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests++
+	if notModified {
+		c.notModified++
+	}
+	if page != nil {
+		c.current[url] = *page
+	}
+}
+
+type cachingTransport struct {
+	cache *PageCache
+	base  http.RoundTripper
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+
+	url := req.URL.String()
+	cached, ok := t.cache.lookup(url)
+	if ok {
+		req = req.Clone(req.Context())
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		t.cache.record(url, &cached, true)
+		return cached.response(req), nil
+	}
+
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode != http.StatusOK || (etag == "" && lastModified == "") {
+		t.cache.record(url, nil, false)
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Only JSON bodies are kept; anything else is not a listing
+	if !json.Valid(body) {
+		t.cache.record(url, nil, false)
+		return resp, nil
+	}
+	page := Page{ETag: etag, LastModified: lastModified, Header: map[string]string{}, Body: body}
+	for _, key := range keptHeaders {
+		if v := resp.Header.Get(key); v != "" {
+			page.Header[key] = v
+		}
+	}
+	t.cache.record(url, &page, false)
+	return resp, nil
+}
+
+// response rebuilds the cached response to req.
+func (p Page) response(req *http.Request) *http.Response {
+	header := http.Header{}
+	for key, v := range p.Header {
+		header.Set(key, v)
+	}
+	if p.ETag != "" {
+		header.Set("ETag", p.ETag)
+	}
+	if p.LastModified != "" {
+		header.Set("Last-Modified", p.LastModified)
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(p.Body)),
+		ContentLength: int64(len(p.Body)),
+		Request:       req,
+	}
+}
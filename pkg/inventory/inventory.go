@@ -0,0 +1,233 @@
+// Package inventory matches the repositories found by the local scan with the
+// repositories fetched from the providers, and reports the local work that
+// has no remote counterpart and the remote repositories never cloned.
+package inventory
+
+import (
+	"christopherharwell/project_monorepo/pkg/local"
+	"christopherharwell/project_monorepo/pkg/types"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Match is a local clone of a fetched repository.
+type Match struct {
+	Local local.LocalRepo
+	Repo  types.Repo
+
+	// Remote is the name of the local remote pointing at Repo
+	Remote string
+}
+
+// Orphan is a local clone whose remotes point at owners or namespaces the
+// configured providers list repositories of, but at no repository they list.
+type Orphan struct {
+	Local local.LocalRepo
+
+	// URLs are the remote URLs that match no fetched repository
+	URLs []string
+}
+
+// Report is the result of matching local clones with fetched repositories.
+type Report struct {
+	// Matched are the local clones of fetched repositories
+	Matched []Match
+
+	// LocalOnly are the local repositories without any remote
+	LocalOnly []local.LocalRepo
+
+	// Orphaned are the local clones whose repository no longer exists upstream
+	Orphaned []Orphan
+
+	// Untracked are the local clones whose remotes are on hosts, or of owners,
+	// no provider lists repositories of
+	Untracked []local.LocalRepo
+
+	// RemoteOnly are the fetched repositories without a local clone
+	RemoteOnly []types.Repo
+}
+
+// NormalizeURL reduces a git URL to "host/path" in lower case, without
+// credentials, port or ".git" suffix, so the SSH and HTTPS URLs of a
+// repository compare equal. Local paths become "file:" followed by the clean
+// absolute path. Azure DevOps SSH and HTTPS URLs are normalized to the same form.
+//
+// Parameters:
+//   - rawURL: A URL in any form git accepts
+//
+// Returns:
+//   - string: The normalized URL, or an empty string for an empty URL
+func NormalizeURL(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return ""
+	}
+
+	var host, p string
+	if u, err := url.Parse(rawURL); err == nil && u.Scheme != "" && u.Host != "" {
+		host, p = u.Hostname(), u.Path
+	} else if u != nil && u.Scheme == "file" {
+		return "file:" + filepath.Clean(u.Path)
+	} else if at := strings.Index(rawURL, ":"); at > 0 && !strings.Contains(rawURL[:at], "/") && !filepath.IsAbs(rawURL) {
+		// scp-like syntax: [user@]host:path
+		host, p = rawURL[:at], rawURL[at+1:]
+		if i := strings.LastIndex(host, "@"); i >= 0 {
+			host = host[i+1:]
+		}
+	} else {
+		abs, err := filepath.Abs(rawURL)
+		if err != nil {
+			abs = rawURL
+		}
+		return "file:" + filepath.Clean(abs)
+	}
+
+	host = strings.ToLower(host)
+	p = strings.ToLower(strings.Trim(path.Clean("/"+p), "/"))
+	p = strings.TrimSuffix(p, ".git")
+
+	// Azure DevOps serves ssh.dev.azure.com:v3/org/project/repo and
+	// dev.azure.com/org/project/_git/repo
+	switch host {
+	case "ssh.dev.azure.com":
+		host, p = "dev.azure.com", strings.TrimPrefix(p, "v3/")
+	case "dev.azure.com":
+		p = strings.Replace(p, "/_git/", "/", 1)
+	}
+	return host + "/" + p
+}
+
+// normalizeRemote normalizes a remote URL of the repository at dir, where
+// relative paths are resolved against dir like git does.
+func normalizeRemote(rawURL string, dir string) string {
+	if n := NormalizeURL(rawURL); !strings.HasPrefix(n, "file:") || filepath.IsAbs(rawURL) || strings.HasPrefix(rawURL, "file:") {
+		return n
+	}
+	return NormalizeURL(filepath.Join(dir, rawURL))
+}
+
+// namespaceOf returns the owner or namespace of a normalized URL with its
+// host, e.g. "github.com/acme" for "github.com/acme/api", or the directory
+// of a local path.
+func namespaceOf(normalized string) string {
+	return path.Dir(normalized)
+}
+
+// Reconcile matches the scanned local repositories with the fetched ones by
+// their normalized remote URLs. A local repository whose remotes point at an
+// owner or namespace that one of the repos belongs to, but at none of the
+// repos, is reported as orphaned; remotes of other owners, even on the same
+// host, cannot be checked.
+//
+// Parameters:
+//   - locals: The results of the local scan; plain directories are ignored
+//   - repos: The repositories fetched from the providers
+//
+// Returns:
+//   - Report: The matched, local-only, orphaned, untracked and remote-only repositories
+func Reconcile(locals []local.LocalRepo, repos []types.Repo) Report {
+	byURL := map[string]int{}
+	namespaces := map[string]bool{}
+	for i, r := range repos {
+		for _, u := range []string{r.SSHURL, r.HTTPSURL, r.WebURL} {
+			if n := NormalizeURL(u); n != "" {
+				if _, taken := byURL[n]; !taken {
+					byURL[n] = i
+				}
+				namespaces[namespaceOf(n)] = true
+			}
+		}
+	}
+
+	var report Report
+	cloned := make([]bool, len(repos))
+	for _, l := range locals {
+		if !l.IsGitRepo {
+			continue
+		}
+		if len(l.Remotes) == 0 {
+			report.LocalOnly = append(report.LocalOnly, l)
+			continue
+		}
+
+		matched := false
+		var orphanURLs []string
+		for _, remote := range l.Remotes {
+			for _, u := range []string{remote.URL, remote.PushURL} {
+				n := normalizeRemote(u, l.Path)
+				if n == "" {
+					continue
+				}
+				if i, ok := byURL[n]; ok {
+					if !matched {
+						report.Matched = append(report.Matched, Match{Local: l, Repo: repos[i], Remote: remote.Name})
+					}
+					matched = true
+					cloned[i] = true
+				} else if namespaces[namespaceOf(n)] {
+					orphanURLs = append(orphanURLs, u)
+				}
+			}
+		}
+		switch {
+		case matched:
+		case len(orphanURLs) > 0:
+			report.Orphaned = append(report.Orphaned, Orphan{Local: l, URLs: orphanURLs})
+		default:
+			report.Untracked = append(report.Untracked, l)
+		}
+	}
+
+	for i, r := range repos {
+		if !cloned[i] {
+			report.RemoteOnly = append(report.RemoteOnly, r)
+		}
+	}
+	sort.SliceStable(report.RemoteOnly, func(i, j int) bool {
+		return displayName(report.RemoteOnly[i]) < displayName(report.RemoteOnly[j])
+	})
+	return report
+}
+
+func displayName(r types.Repo) string {
+	name := r.FullPath
+	if name == "" {
+		name = r.Name
+	}
+	return r.Provider + ":" + name
+}
+
+// PrintReport prints the reconciliation of local clones and fetched repositories to w.
+func PrintReport(w io.Writer, r Report) {
+	fmt.Fprintf(w, "\nLocal clones matched to fetched repositories: %d\n", len(r.Matched))
+	for _, m := range r.Matched {
+		fmt.Fprintf(w, "  %-40s %s (%s)\n", m.Local.Path, displayName(m.Repo), m.Remote)
+	}
+
+	fmt.Fprintf(w, "\nLocal-only repositories, never pushed anywhere: %d\n", len(r.LocalOnly))
+	for _, l := range r.LocalOnly {
+		fmt.Fprintf(w, "  %s\n", l.Path)
+	}
+
+	fmt.Fprintf(w, "\nOrphaned clones, their repository no longer exists upstream: %d\n", len(r.Orphaned))
+	for _, o := range r.Orphaned {
+		fmt.Fprintf(w, "  %-40s %s\n", o.Local.Path, strings.Join(o.URLs, ", "))
+	}
+
+	if len(r.Untracked) > 0 {
+		fmt.Fprintf(w, "\nClones of hosts or owners no provider lists: %d\n", len(r.Untracked))
+		for _, l := range r.Untracked {
+			fmt.Fprintf(w, "  %-40s %s\n", l.Path, l.Remotes[0].URL)
+		}
+	}
+
+	fmt.Fprintf(w, "\nRemote repositories never cloned: %d\n", len(r.RemoteOnly))
+	for _, repo := range r.RemoteOnly {
+		fmt.Fprintf(w, "  %s\n", displayName(repo))
+	}
+}
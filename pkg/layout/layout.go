@@ -0,0 +1,264 @@
+// Package layout decides where each repository is placed inside the
+// monorepo's repos directory and resolves paths claimed by more than one
+// repository.
+package layout
+
+import (
+	"christopherharwell/project_monorepo/pkg/git"
+	"christopherharwell/project_monorepo/pkg/types"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultTemplate places every repository directly under repos/ by name.
+const DefaultTemplate = "{name}"
+
+var (
+	placeholderPattern = regexp.MustCompile(`\{[a-z_]+\}`)
+	unsafeChars        = regexp.MustCompile(`[^A-Za-z0-9._/-]+`)
+)
+
+// placeholders maps the supported template placeholders to repository fields.
+var placeholders = map[string]func(types.Repo) string{
+	"{name}":      func(r types.Repo) string { return r.Name },
+	"{owner}":     func(r types.Repo) string { return r.Owner },
+	"{provider}":  func(r types.Repo) string { return r.Provider },
+	"{full_path}": func(r types.Repo) string { return r.FullPath },
+}
+
+// Collision describes a path claimed by several distinct repositories and
+// the paths they were given instead.
+type Collision struct {
+	// Path is the contested path produced by the template
+	Path string
+
+	// Repos are the colliding repositories in resolution order, each with its final Path
+	Repos []types.Repo
+}
+
+// Validate reports an error when template is empty, references an unknown
+// placeholder or does not contain {name} or {full_path}.
+func Validate(template string) error {
+	if strings.TrimSpace(template) == "" {
+		return fmt.Errorf("path layout is empty")
+	}
+	for _, p := range placeholderPattern.FindAllString(template, -1) {
+		if _, ok := placeholders[p]; !ok {
+			return fmt.Errorf("path layout %q: unknown placeholder %s", template, p)
+		}
+	}
+	if !strings.Contains(template, "{name}") && !strings.Contains(template, "{full_path}") {
+		return fmt.Errorf("path layout %q must contain {name} or {full_path}", template)
+	}
+	return nil
+}
+
+// Expand renders template for r. Placeholder values are sanitized so the
+// result is always a clean relative path that stays inside repos/.
+func Expand(template string, r types.Repo) string {
+	expanded := placeholderPattern.ReplaceAllStringFunc(template, func(p string) string {
+		field, ok := placeholders[p]
+		if !ok {
+			return p
+		}
+		return sanitize(field(r))
+	})
+
+	var segments []string
+	for _, segment := range strings.Split(path.Clean("/"+expanded), "/") {
+		if segment != "" && segment != "." && segment != ".." {
+			segments = append(segments, segment)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func sanitize(value string) string {
+	return unsafeChars.ReplaceAllString(value, "-")
+}
+
+// Assign sets the Path of every repository according to template. Entries
+// describing the same repository (same provider and full path, or same
+// clone URL) are merged into one. Repositories that are already part of the
+// monorepo keep their recorded path, and no other repository is given a
+// recorded path, even when the repository recorded there is not in repos.
+// When distinct repositories still share a path, they are ordered by
+// provider, full path and URL; the first keeps the path unless a recorded
+// repository holds it, and the others get the owner, then the provider, then
+// a number appended until the path is unique.
+//
+// Parameters:
+//   - repos: The repositories to place
+//   - template: The path layout, e.g. "{provider}/{owner}/{name}"; empty means DefaultTemplate
+//   - recorded: The repositories already in the monorepo, with their Path, e.g. from the lockfile
+//
+// Returns:
+//   - []types.Repo: The deduplicated repositories with Path set, in input order
+//   - []Collision: The collisions that were resolved, ordered by path
+//   - error: An error when template is invalid
+func Assign(repos []types.Repo, template string, recorded []types.Repo) ([]types.Repo, []Collision, error) {
+	if template == "" {
+		template = DefaultTemplate
+	}
+	if err := Validate(template); err != nil {
+		return nil, nil, err
+	}
+
+	// Every recorded path is reserved, also for repositories that are not
+	// listed this time because they were filtered out or their provider failed
+	taken := map[string]bool{}
+	holders := map[string]types.Repo{}
+	for _, rec := range recorded {
+		if rec.Path != "" && !taken[rec.Path] {
+			taken[rec.Path] = true
+			holders[rec.Path] = rec
+		}
+	}
+
+	unique := dedupe(repos)
+	claimed := map[string]bool{}
+	fixed := make([]bool, len(unique))
+	for i := range unique {
+		if p, ok := recordedPath(unique[i], recorded); ok && !claimed[p] {
+			unique[i].Path = p
+			claimed[p] = true
+			holders[p] = unique[i]
+			fixed[i] = true
+		}
+	}
+
+	byPath := map[string][]int{}
+	var order []string
+	for i := range unique {
+		if fixed[i] {
+			continue
+		}
+		p := Expand(template, unique[i])
+		unique[i].Path = p
+		if _, ok := byPath[p]; !ok {
+			order = append(order, p)
+		}
+		byPath[p] = append(byPath[p], i)
+	}
+	for _, p := range order {
+		taken[p] = true
+	}
+
+	var collisions []Collision
+	sort.Strings(order)
+	for _, p := range order {
+		indexes := byPath[p]
+		holder, held := holders[p]
+		if len(indexes) < 2 && !held {
+			continue
+		}
+		sort.SliceStable(indexes, func(a, b int) bool {
+			return sortKey(unique[indexes[a]]) < sortKey(unique[indexes[b]])
+		})
+
+		collision := Collision{Path: p}
+		if held {
+			collision.Repos = append(collision.Repos, holder)
+		}
+		for n, i := range indexes {
+			if n > 0 || held {
+				unique[i].Path = alternativePath(p, unique[i], taken)
+				taken[unique[i].Path] = true
+			}
+			collision.Repos = append(collision.Repos, unique[i])
+		}
+		collisions = append(collisions, collision)
+	}
+	return unique, collisions, nil
+}
+
+// recordedPath returns the path recorded for r: that of the recorded
+// repository with the same provider and full path, or the same clone URL.
+func recordedPath(r types.Repo, recorded []types.Repo) (string, bool) {
+	for _, rec := range recorded {
+		if rec.Path == "" {
+			continue
+		}
+		samePath := r.FullPath != "" && rec.FullPath == r.FullPath && rec.Provider == r.Provider
+		sameURL := r.SSHURL != "" && git.StripCredentials(rec.SSHURL) == git.StripCredentials(r.SSHURL)
+		if samePath || sameURL {
+			return rec.Path, true
+		}
+	}
+	return "", false
+}
+
+func sortKey(r types.Repo) string {
+	return r.Provider + "\x00" + r.FullPath + "\x00" + r.SSHURL
+}
+
+// alternativePath appends disambiguating suffixes to p until it is not taken.
+func alternativePath(p string, r types.Repo, taken map[string]bool) string {
+	candidate := p
+	for _, suffix := range []string{r.Owner, r.Provider} {
+		suffix = strings.ReplaceAll(sanitize(suffix), "/", "-")
+		if suffix == "" {
+			continue
+		}
+		candidate += "-" + suffix
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+	for n := 2; ; n++ {
+		numbered := fmt.Sprintf("%s-%d", candidate, n)
+		if !taken[numbered] {
+			return numbered
+		}
+	}
+}
+
+// dedupe drops repeated entries for the same repository, which happens for
+// example when a GitHub organization repository is listed both for the user
+// and for the organization.
+func dedupe(repos []types.Repo) []types.Repo {
+	var unique []types.Repo
+	seen := map[string]bool{}
+	for _, r := range repos {
+		var keys []string
+		if r.FullPath != "" {
+			keys = append(keys, "path:"+r.Provider+"\x00"+r.FullPath)
+		}
+		if r.SSHURL != "" {
+			keys = append(keys, "url:"+r.SSHURL)
+		}
+
+		duplicate := false
+		for _, k := range keys {
+			duplicate = duplicate || seen[k]
+			seen[k] = true
+		}
+		if !duplicate {
+			unique = append(unique, r)
+		}
+	}
+	return unique
+}
+
+// PrintCollisions prints a warning report of the resolved collisions to w.
+func PrintCollisions(w io.Writer, collisions []Collision) {
+	if len(collisions) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nWarning: %d path collision(s) found, the repositories were given distinct paths:\n", len(collisions))
+	for _, c := range collisions {
+		fmt.Fprintf(w, "  repos/%s\n", c.Path)
+		for _, r := range c.Repos {
+			source := r.FullPath
+			if source == "" {
+				source = r.SSHURL
+			}
+			fmt.Fprintf(w, "    %-40s -> repos/%s\n", fmt.Sprintf("%s (%s)", source, r.Provider), r.Path)
+		}
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,318 @@
+package local
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// AdoptionsFile is the default journal of the directories initialized by Adopt.
+const AdoptionsFile = "adopted_repos.json"
+
+// Candidate is a directory proposed for adoption or undo, and whether the
+// operation would change it.
+type Candidate struct {
+	// Path is the absolute path of the directory
+	Path string
+
+	// Ready is set when the operation would change the directory
+	Ready bool
+
+	// Reason explains why a directory is left alone
+	Reason string
+}
+
+// Adoption records a directory initialized by Adopt so it can be undone.
+type Adoption struct {
+	// Path is the absolute path of the adopted directory
+	Path string `json:"path"`
+
+	// AdoptedAt is when git init ran
+	AdoptedAt time.Time `json:"adopted_at"`
+}
+
+// PreviewAdopt decides which of paths would be initialized as git
+// repositories. Missing paths, files, repositories, directories inside a
+// repository and the monorepo with its directories are left alone; the
+// monorepo may not have been initialized yet.
+//
+// Parameters:
+//   - paths: The directories chosen by the user
+//   - monorepoPath: Path to the monorepo
+//
+// Returns:
+//   - []Candidate: One candidate per distinct path, in order
+//   - error: Any error that occurred while resolving a path
+func PreviewAdopt(paths []string, monorepoPath string) ([]Candidate, error) {
+	monorepoPath, err := filepath.Abs(monorepoPath)
+	if err != nil {
+		return nil, fmt.Errorf("error getting absolute path: %w", err)
+	}
+
+	var candidates []Candidate
+	seen := map[string]bool{}
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, fmt.Errorf("error getting absolute path: %w", err)
+		}
+		if seen[abs] {
+			continue
+		}
+		seen[abs] = true
+
+		c := Candidate{Path: abs}
+		info, err := os.Stat(abs)
+		switch {
+		case err != nil:
+			c.Reason = "does not exist"
+		case !info.IsDir():
+			c.Reason = "is not a directory"
+		case strings.Contains(filepath.ToSlash(abs)+"/", "/.git/"):
+			c.Reason = "is inside git metadata"
+		case isRepoInMonorepo(abs, monorepoPath):
+			c.Reason = "is part of the monorepo"
+		default:
+			if top, err := gitOutput(abs, "rev-parse", "--show-toplevel"); err == nil {
+				if sameDir(top, abs) {
+					c.Reason = "is already a git repository"
+				} else {
+					c.Reason = "is inside the git repository at " + top
+				}
+			} else {
+				c.Ready = true
+			}
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, nil
+}
+
+// Adopt runs git init in every ready candidate and records the initialized
+// directories in the journal, so Undo can revert them. No identity or other
+// configuration is written to the new repositories.
+//
+// Parameters:
+//   - candidates: The candidates returned by PreviewAdopt
+//   - journal: Path to the journal file
+//
+// Returns:
+//   - []Adoption: The directories that were initialized
+//   - error: An error listing the directories that could not be initialized, or any journal error
+func Adopt(candidates []Candidate, journal string) ([]Adoption, error) {
+	adoptions, err := LoadAdoptions(journal)
+	if err != nil {
+		return nil, err
+	}
+
+	var adopted []Adoption
+	var failed []string
+	for _, c := range candidates {
+		if !c.Ready {
+			continue
+		}
+		cmd := exec.Command("git", "init", "-q", "-b", "main")
+		cmd.Dir = c.Path
+		if out, err := cmd.CombinedOutput(); err != nil {
+			fmt.Printf("Error initializing %s: %v: %s\n", c.Path, err, strings.TrimSpace(string(out)))
+			failed = append(failed, c.Path)
+			continue
+		}
+		a := Adoption{Path: c.Path, AdoptedAt: time.Now().UTC()}
+		adopted = append(adopted, a)
+		adoptions = append(withoutAdoption(adoptions, c.Path), a)
+		fmt.Printf("Initialized %s\n", c.Path)
+	}
+
+	if len(adopted) > 0 {
+		if err := saveAdoptions(journal, adoptions); err != nil {
+			return adopted, fmt.Errorf("error recording adoptions in %s: %w", journal, err)
+		}
+	}
+	if len(failed) > 0 {
+		return adopted, fmt.Errorf("failed to initialize %s", strings.Join(failed, ", "))
+	}
+	return adopted, nil
+}
+
+// PreviewUndo decides which adopted directories would have their .git
+// directory removed. Only repositories still without commits are reverted,
+// so no history is ever deleted.
+//
+// Parameters:
+//   - journal: Path to the journal file
+//   - paths: The adopted directories to revert; empty selects all of them
+//
+// Returns:
+//   - []Candidate: One candidate per selected adoption
+//   - error: An error naming a path that was never adopted, or any journal error
+func PreviewUndo(journal string, paths []string) ([]Candidate, error) {
+	adoptions, err := LoadAdoptions(journal)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := adoptions
+	if len(paths) > 0 {
+		selected = nil
+		for _, p := range paths {
+			abs, err := filepath.Abs(p)
+			if err != nil {
+				return nil, fmt.Errorf("error getting absolute path: %w", err)
+			}
+			a, ok := findAdoption(adoptions, abs)
+			if !ok {
+				return nil, fmt.Errorf("%s is not recorded in %s", abs, journal)
+			}
+			selected = append(selected, a)
+		}
+	}
+
+	var candidates []Candidate
+	for _, a := range selected {
+		c := Candidate{Path: a.Path}
+		info, err := os.Stat(filepath.Join(a.Path, ".git"))
+		switch {
+		case err != nil || !info.IsDir():
+			c.Reason = "has no .git directory anymore"
+		case hasCommits(a.Path):
+			c.Reason = "has commits since it was adopted, remove its .git directory by hand"
+		default:
+			c.Ready = true
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, nil
+}
+
+// Undo removes the .git directory of every ready candidate and drops the
+// reverted directories, and those without a .git directory, from the journal.
+//
+// Parameters:
+//   - candidates: The candidates returned by PreviewUndo
+//   - journal: Path to the journal file
+//
+// Returns:
+//   - []string: The directories that were reverted
+//   - error: Any error that occurred while removing a .git directory or writing the journal
+func Undo(candidates []Candidate, journal string) ([]string, error) {
+	adoptions, err := LoadAdoptions(journal)
+	if err != nil {
+		return nil, err
+	}
+
+	drop := map[string]bool{}
+	var reverted []string
+	var errs []error
+	for _, c := range candidates {
+		if !c.Ready {
+			if !dirExists(filepath.Join(c.Path, ".git")) {
+				drop[c.Path] = true
+			}
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(c.Path, ".git")); err != nil {
+			errs = append(errs, fmt.Errorf("error reverting %s: %w", c.Path, err))
+			continue
+		}
+		drop[c.Path] = true
+		reverted = append(reverted, c.Path)
+		fmt.Printf("Reverted %s\n", c.Path)
+	}
+
+	for p := range drop {
+		adoptions = withoutAdoption(adoptions, p)
+	}
+	if err := saveAdoptions(journal, adoptions); err != nil {
+		errs = append(errs, fmt.Errorf("error updating %s: %w", journal, err))
+	}
+	return reverted, errors.Join(errs...)
+}
+
+// PrintCandidates prints to w the preview of an adopt or undo operation, described
+// by action, e.g. "initialize".
+func PrintCandidates(w io.Writer, action string, candidates []Candidate) {
+	ready := 0
+	for _, c := range candidates {
+		if c.Ready {
+			ready++
+			fmt.Fprintf(w, "  %-10s %s\n", action, c.Path)
+		} else {
+			fmt.Fprintf(w, "  %-10s %s %s\n", "skip", c.Path, c.Reason)
+		}
+	}
+	fmt.Fprintf(w, "%d of %d directories to %s\n", ready, len(candidates), action)
+}
+
+// LoadAdoptions reads the journal. A missing journal yields no adoptions.
+func LoadAdoptions(journal string) ([]Adoption, error) {
+	data, err := os.ReadFile(journal)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var adoptions []Adoption
+	if err := json.Unmarshal(data, &adoptions); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", journal, err)
+	}
+	return adoptions, nil
+}
+
+func saveAdoptions(journal string, adoptions []Adoption) error {
+	if adoptions == nil {
+		adoptions = []Adoption{}
+	}
+	data, err := json.MarshalIndent(adoptions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(journal, append(data, '\n'), 0644)
+}
+
+func findAdoption(adoptions []Adoption, path string) (Adoption, bool) {
+	for _, a := range adoptions {
+		if a.Path == path {
+			return a, true
+		}
+	}
+	return Adoption{}, false
+}
+
+func withoutAdoption(adoptions []Adoption, path string) []Adoption {
+	var kept []Adoption
+	for _, a := range adoptions {
+		if a.Path != path {
+			kept = append(kept, a)
+		}
+	}
+	return kept
+}
+
+func hasCommits(path string) bool {
+	_, err := gitOutput(path, "rev-parse", "--verify", "-q", "HEAD")
+	return err == nil
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// sameDir reports whether a and b name the same directory, resolving symlinks
+// such as macOS's /tmp.
+func sameDir(a string, b string) bool {
+	ra, errA := filepath.EvalSymlinks(a)
+	rb, errB := filepath.EvalSymlinks(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return ra == rb
+}
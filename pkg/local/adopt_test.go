@@ -0,0 +1,107 @@
+package local
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestAdoptAndUndo(t *testing.T) {
+	tmpDir := t.TempDir()
+	journal := filepath.Join(tmpDir, AdoptionsFile)
+
+	plain := filepath.Join(tmpDir, "plain")
+	committed := filepath.Join(tmpDir, "committed")
+	existing := filepath.Join(tmpDir, "existing")
+	mono := filepath.Join(tmpDir, "mono")
+	member := filepath.Join(mono, "repos", "member")
+	for _, dir := range []string{plain, committed, existing, member} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := exec.Command("git", "-C", existing, "init").Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	candidates, err := PreviewAdopt([]string{plain, committed, existing, filepath.Join(existing, "sub"), plain, mono, member}, mono)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Candidate{
+		{Path: plain, Ready: true},
+		{Path: committed, Ready: true},
+		{Path: existing, Reason: "is already a git repository"},
+		{Path: filepath.Join(existing, "sub"), Reason: "does not exist"},
+		{Path: mono, Reason: "is part of the monorepo"},
+		{Path: member, Reason: "is part of the monorepo"},
+	}
+	if len(candidates) != len(want) {
+		t.Fatalf("PreviewAdopt = %+v, want %+v", candidates, want)
+	}
+	for i := range want {
+		if candidates[i] != want[i] {
+			t.Errorf("candidate %d = %+v, want %+v", i, candidates[i], want[i])
+		}
+	}
+
+	// The preview changes nothing
+	if _, err := os.Stat(filepath.Join(plain, ".git")); !os.IsNotExist(err) {
+		t.Fatal("PreviewAdopt initialized a repository")
+	}
+
+	adopted, err := Adopt(candidates, journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(adopted) != 2 {
+		t.Fatalf("Adopt = %+v, want 2 adoptions", adopted)
+	}
+	if !isGitRepository(plain) || !isGitRepository(committed) {
+		t.Fatal("Adopted directories are not git repositories")
+	}
+	if out, err := exec.Command("git", "-C", plain, "config", "--local", "user.email").Output(); err == nil {
+		t.Errorf("Adopt configured an identity: %s", out)
+	}
+
+	// A repository with history is never reverted
+	commit := exec.Command("git", "-C", committed, "-c", "user.name=t", "-c", "user.email=t@t", "commit", "--allow-empty", "-m", "work")
+	if out, err := commit.CombinedOutput(); err != nil {
+		t.Fatalf("git commit: %v: %s", err, out)
+	}
+
+	candidates, err = PreviewUndo(journal, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 2 || !candidates[0].Ready || candidates[1].Ready {
+		t.Fatalf("PreviewUndo = %+v, want only %s ready", candidates, plain)
+	}
+
+	reverted, err := Undo(candidates, journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != 1 || reverted[0] != plain {
+		t.Errorf("Undo = %v, want [%s]", reverted, plain)
+	}
+	if _, err := os.Stat(filepath.Join(plain, ".git")); !os.IsNotExist(err) {
+		t.Error("Undo left .git behind")
+	}
+	if !isGitRepository(committed) {
+		t.Error("Undo removed a repository with commits")
+	}
+
+	remaining, err := LoadAdoptions(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 1 || remaining[0].Path != committed {
+		t.Errorf("journal = %+v, want only %s", remaining, committed)
+	}
+
+	if _, err := PreviewUndo(journal, []string{existing}); err == nil {
+		t.Error("PreviewUndo accepted a directory that was never adopted")
+	}
+}
@@ -0,0 +1,170 @@
+package local
+
+import (
+	"christopherharwell/project_monorepo/pkg/git"
+	"christopherharwell/project_monorepo/pkg/types"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ProviderName is the provider recorded for repositories imported from a
+// local directory. No provider of that name is configured, so their URL is
+// used as is when they are fetched or pushed.
+const ProviderName = "local"
+
+// Inspect reads the state of the directory at path the way the scan does for
+// every directory it finds. Directories other than the root of a repository
+// are returned with IsGitRepo unset, and repositories inside another one have
+// the closest of them as ParentRepo.
+//
+// Parameters:
+//   - path: The directory to inspect
+//   - monorepoPath: Path to the monorepo, whose repositories cannot be imported
+//
+// Returns:
+//   - LocalRepo: The directory, and for a repository its branch, last commit, remotes and working tree state
+//   - error: An error when path is not a directory
+func Inspect(path string, monorepoPath string) (LocalRepo, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return LocalRepo{}, fmt.Errorf("error getting absolute path: %w", err)
+	}
+	if !dirExists(abs) {
+		return LocalRepo{}, fmt.Errorf("%s is not a directory", abs)
+	}
+	monorepoPath, err = filepath.Abs(monorepoPath)
+	if err != nil {
+		return LocalRepo{}, fmt.Errorf("error getting absolute path: %w", err)
+	}
+
+	repo := LocalRepo{
+		Path:         abs,
+		Name:         filepath.Base(abs),
+		IsGitRepo:    isRepoRoot(abs),
+		IsInMonorepo: isRepoInMonorepo(abs, monorepoPath),
+		ParentRepo:   parentRepo(abs, monorepoPath),
+	}
+	if repo.IsGitRepo {
+		probeRepo(&repo)
+	}
+	return repo, nil
+}
+
+// parentRepo returns the closest directory above path that is a repository,
+// counting the monorepo as one even before git init ran in it, or an empty
+// string when there is none.
+func parentRepo(path string, monorepoPath string) string {
+	for dir := filepath.Dir(path); dir != path; path, dir = dir, filepath.Dir(dir) {
+		if dir == monorepoPath || isRepoRoot(dir) {
+			return dir
+		}
+	}
+	return ""
+}
+
+// ImportProblem explains why the repository cannot be imported into the
+// monorepo, or returns an empty string when it can.
+func (r LocalRepo) ImportProblem() string {
+	switch {
+	case r.IsInMonorepo:
+		return "is part of the monorepo"
+	case !r.IsGitRepo:
+		return "is not the root of a git repository"
+	case r.ParentRepo != "":
+		return "is nested in " + r.ParentRepo
+	case r.DefaultBranch == "":
+		return "has no branch checked out"
+	case r.LastCommitHash == "":
+		return "has no commits"
+	}
+	return ""
+}
+
+// Repo returns the repository to integrate for the local repository: the
+// tip of its current branch, fetched straight from its directory, with the
+// directory recorded as its origin.
+func (r LocalRepo) Repo() types.Repo {
+	return types.Repo{
+		Name:          r.Name,
+		SSHURL:        r.Path,
+		DefaultBranch: r.DefaultBranch,
+		Provider:      ProviderName,
+		Origin:        r.Path,
+	}
+}
+
+// CreateRemote gives the local repository a remote to live on outside the
+// laptop. The repository at remoteURL is created as a bare repository when
+// remoteURL is a local path that does not exist yet; other URLs must name an
+// existing, empty repository. The remote is added to the local repository as
+// name, unless it already points there, and the current branch is pushed to
+// it and set as its upstream.
+//
+// Parameters:
+//   - r: The local repository
+//   - name: The name of the remote in the local repository
+//   - remoteURL: The URL of the new remote
+//
+// Returns:
+//   - error: Any error that occurred while creating, adding or pushing to the remote
+func CreateRemote(r LocalRepo, name string, remoteURL string) error {
+	for _, remote := range r.Remotes {
+		if remote.Name == name && remote.URL != remoteURL {
+			return fmt.Errorf("%s already has a remote %s pointing at %s", r.Path, name, remote.URL)
+		}
+	}
+
+	if p, ok := LocalPath(remoteURL); ok && !dirExists(p) {
+		if out, err := exec.Command("git", "init", "-q", "--bare", "-b", r.DefaultBranch, p).CombinedOutput(); err != nil {
+			return fmt.Errorf("error creating %s: %v: %s", p, err, strings.TrimSpace(string(out)))
+		}
+		fmt.Printf("Created bare repository %s\n", p)
+	}
+
+	if !hasRemote(r, name) {
+		if err := gitRun(r.Path, "remote", "add", name, remoteURL); err != nil {
+			return err
+		}
+	}
+	if err := gitRun(r.Path, "push", "-q", "-u", name, r.DefaultBranch); err != nil {
+		return err
+	}
+	fmt.Printf("Pushed %s of %s to %s\n", r.DefaultBranch, r.Path, remoteURL)
+	return nil
+}
+
+func hasRemote(r LocalRepo, name string) bool {
+	for _, remote := range r.Remotes {
+		if remote.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// LocalPath reports whether rawURL names a repository on the local file
+// system rather than on a host, and returns its absolute path.
+func LocalPath(rawURL string) (string, bool) {
+	if !git.IsLocalURL(rawURL) {
+		return "", false
+	}
+	if p, ok := strings.CutPrefix(rawURL, "file://"); ok {
+		return filepath.Clean(p), true
+	}
+	abs, err := filepath.Abs(rawURL)
+	if err != nil {
+		return "", false
+	}
+	return abs, true
+}
+
+func gitRun(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
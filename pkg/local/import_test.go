@@ -0,0 +1,110 @@
+package local
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestInspectAndCreateRemote(t *testing.T) {
+	tmpDir := t.TempDir()
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=Dev", "-c", "user.email=dev@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+		return string(out)
+	}
+
+	mono := filepath.Join(tmpDir, "mono")
+	plain := filepath.Join(tmpDir, "plain")
+	empty := filepath.Join(tmpDir, "empty")
+	notes := filepath.Join(tmpDir, "notes")
+	member := filepath.Join(mono, "repos", "member")
+	nested := filepath.Join(notes, "vendor", "lib")
+	for _, dir := range []string{plain, empty, notes, member, nested} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git(empty, "init", "-q", "-b", "main")
+	for _, dir := range []string{notes, member, nested} {
+		git(dir, "init", "-q", "-b", "trunk")
+		git(dir, "commit", "-q", "--allow-empty", "-m", "first")
+	}
+
+	problems := map[string]string{
+		plain:  "is not the root of a git repository",
+		empty:  "has no commits",
+		notes:  "",
+		mono:   "is part of the monorepo",
+		member: "is part of the monorepo",
+		nested: "is nested in " + notes,
+	}
+	for dir, want := range problems {
+		r, err := Inspect(dir, mono)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.ImportProblem(); got != want {
+			t.Errorf("ImportProblem(%s) = %q, want %q", dir, got, want)
+		}
+	}
+	if _, err := Inspect(filepath.Join(tmpDir, "missing"), mono); err == nil {
+		t.Error("Inspect accepted a missing directory")
+	}
+
+	r, err := Inspect(notes, mono)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := r.Repo()
+	if repo.Name != "notes" || repo.SSHURL != notes || repo.Origin != notes || repo.DefaultBranch != "trunk" || repo.Provider != ProviderName {
+		t.Errorf("Repo() = %+v", repo)
+	}
+
+	// The bare repository is created on first use and receives the branch
+	remote := filepath.Join(tmpDir, "remotes", "notes.git")
+	if err := CreateRemote(r, "origin", remote); err != nil {
+		t.Fatal(err)
+	}
+	if out := git(remote, "rev-parse", "trunk"); out == "" {
+		t.Error("The branch was not pushed to the new remote")
+	}
+	r, err = Inspect(notes, mono)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Remotes) != 1 || r.Remotes[0].URL != remote || r.Upstream != "origin/trunk" {
+		t.Errorf("Remotes %+v upstream %q, want origin at %s tracked by trunk", r.Remotes, r.Upstream, remote)
+	}
+
+	if err := CreateRemote(r, "origin", filepath.Join(tmpDir, "other.git")); err == nil {
+		t.Error("CreateRemote replaced an existing remote")
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	abs, _ := filepath.Abs("backup/notes.git")
+	tests := []struct {
+		url   string
+		want  string
+		local bool
+	}{
+		{"/srv/git/notes.git", "/srv/git/notes.git", true},
+		{"file:///srv/git/notes.git", "/srv/git/notes.git", true},
+		{"backup/notes.git", abs, true},
+		{"nas:/volume1/git/notes.git", "", false},
+		{"git@github.com:acme/notes.git", "", false},
+		{"ssh://nas/volume1/git/notes.git", "", false},
+	}
+	for _, tt := range tests {
+		got, local := LocalPath(tt.url)
+		if got != tt.want || local != tt.local {
+			t.Errorf("LocalPath(%q) = %q, %v, want %q, %v", tt.url, got, local, tt.want, tt.local)
+		}
+	}
+}
@@ -1,12 +1,27 @@
 package local
 
 import (
+	"christopherharwell/project_monorepo/pkg/git"
+	"christopherharwell/project_monorepo/pkg/types"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
 )
 
+// DefaultIgnore lists the directories skipped when the scan configuration
+// sets no ignore patterns: dependency trees, virtual environments, caches
+// and build output.
+var DefaultIgnore = []string{"node_modules", "vendor", ".venv", "venv", "__pycache__", ".tox", ".cache", "target", "build", "dist"}
+
 type LocalRepo struct {
 	Path           string
 	Name           string
@@ -14,22 +29,373 @@ type LocalRepo struct {
 	IsInMonorepo   bool
 	DefaultBranch  string
 	LastCommitHash string
+
+	// ParentRepo is the repository containing this one, for nested
+	// repositories and submodules found with nested detection
+	ParentRepo string
+
+	// LastCommitDate and LastCommitAuthor describe the commit at HEAD
+	LastCommitDate   time.Time
+	LastCommitAuthor string
+
+	// Remotes are the configured remotes, with credentials removed from their URLs
+	Remotes []Remote
+
+	// Modified counts tracked files with staged or unstaged changes, including conflicts
+	Modified int
+
+	// Untracked counts files that are neither tracked nor ignored
+	Untracked int
+
+	// Stashes counts the entries of the stash
+	Stashes int
+
+	// Upstream is the branch the current branch tracks, e.g. "origin/main"
+	Upstream string
+
+	// Ahead and Behind count the commits of the current branch that are not
+	// on Upstream, and the other way round
+	Ahead  int
+	Behind int
+
+	// UnpushedBranches lists the local branches with commits that are on no
+	// remote-tracking branch
+	UnpushedBranches []string
 }
 
-func SearchRepos(baseDir string, monorepoPath string) ([]LocalRepo, error) {
-	var repos []LocalRepo
-	err := filepath.Walk(baseDir, createWalkFunction(monorepoPath, &repos))
-	return repos, err
+// Remote is a configured remote of a local repository.
+type Remote struct {
+	Name string
+
+	// URL is the fetch URL
+	URL string
+
+	// PushURL is set when pushes go to a different URL than fetches
+	PushURL string
 }
 
-func createWalkFunction(monorepoPath string, repos *[]LocalRepo) filepath.WalkFunc {
-	// Implementation moved from main.go
+// AtRisk reports whether the repository holds work that exists nowhere
+// else: uncommitted, untracked or stashed changes, commits missing from every
+// remote, or commits but no remote at all.
+func (r LocalRepo) AtRisk() bool {
+	if !r.IsGitRepo {
+		return false
+	}
+	return r.Modified > 0 || r.Untracked > 0 || r.Stashes > 0 || r.Ahead > 0 || len(r.UnpushedBranches) > 0 || (len(r.Remotes) == 0 && r.LastCommitHash != "")
+}
+
+// SearchRepos walks baseDir and records the repositories found below it,
+// together with the plain directories outside any repository. A repository
+// root is a leaf: its subdirectories are only searched for nested
+// repositories and submodules when opts.Nested is set. The monorepo itself
+// is treated the same way. The scan is read-only; see Adopt to turn
+// directories into repositories.
+//
+// Parameters:
+//   - baseDir: Root directory to scan
+//   - monorepoPath: Path to the monorepo
+//   - opts: Ignore patterns, maximum depth, nested detection and the number of concurrent git probes
+//
+// Returns:
+//   - []LocalRepo: The directories found, in walk order
+//   - error: An invalid ignore pattern or an unreadable baseDir
+func SearchRepos(baseDir string, monorepoPath string, opts types.ScanConfig) ([]LocalRepo, error) {
+	monorepoPath, err := filepath.Abs(monorepoPath)
+	if err != nil {
+		return nil, fmt.Errorf("error getting absolute path: %w", err)
+	}
+	root, err := filepath.Abs(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("error getting absolute path: %w", err)
+	}
+
+	ignore := opts.Ignore
+	if ignore == nil {
+		ignore = DefaultIgnore
+	}
+	for _, pattern := range ignore {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern %q: %w", pattern, err)
+		}
+	}
+
+	w := walker{root: root, monorepoPath: monorepoPath, ignore: ignore, opts: opts}
+	if err := filepath.WalkDir(root, w.visit); err != nil {
+		return nil, fmt.Errorf("error walking directory: %w", err)
+	}
+
+	probeRepos(w.repos, opts.Workers)
+	return w.repos, nil
+}
+
+// walker collects the directories of one scan.
+type walker struct {
+	root         string
+	monorepoPath string
+	ignore       []string
+	opts         types.ScanConfig
+
+	// parents are the repositories enclosing the directory being visited, innermost last
+	parents []string
+	repos   []LocalRepo
+}
+
+func (w *walker) visit(p string, d fs.DirEntry, err error) error {
+	if err != nil {
+		if p == w.root {
+			return err
+		}
+		fmt.Printf("Warning: skipping %s: %v\n", p, err)
+		return nil
+	}
+	if !d.IsDir() {
+		return nil
+	}
+	if p == w.root {
+		if !isRepoRoot(p) {
+			return nil
+		}
+		// A repository scanned as the root is a leaf like any other
+		if !w.opts.Nested {
+			return filepath.SkipDir
+		}
+		w.parents = append(w.parents, p)
+		return nil
+	}
+
+	// Git metadata is never a repository of its own
+	if d.Name() == ".git" {
+		return filepath.SkipDir
+	}
+	rel, _ := filepath.Rel(w.root, p)
+	if w.ignored(filepath.ToSlash(rel), d.Name()) {
+		return filepath.SkipDir
+	}
+
+	for len(w.parents) > 0 && !isWithin(p, w.parents[len(w.parents)-1]) {
+		w.parents = w.parents[:len(w.parents)-1]
+	}
+	parent := ""
+	if len(w.parents) > 0 {
+		parent = w.parents[len(w.parents)-1]
+	}
+
+	// The monorepo is a repository even before git init ran in it
+	isRepo := p == w.monorepoPath || isRepoRoot(p)
+	switch {
+	case p == w.monorepoPath:
+	case isRepo:
+		w.repos = append(w.repos, LocalRepo{
+			Path:         p,
+			Name:         d.Name(),
+			IsGitRepo:    true,
+			IsInMonorepo: isRepoInMonorepo(p, w.monorepoPath),
+			ParentRepo:   parent,
+		})
+	case parent == "":
+		w.repos = append(w.repos, LocalRepo{
+			Path:         p,
+			Name:         d.Name(),
+			IsInMonorepo: isRepoInMonorepo(p, w.monorepoPath),
+		})
+	}
+
+	descend := !isRepo || w.opts.Nested
+	if w.opts.MaxDepth > 0 && strings.Count(filepath.ToSlash(rel), "/")+1 >= w.opts.MaxDepth {
+		descend = false
+	}
+	if !descend {
+		return filepath.SkipDir
+	}
+	if isRepo {
+		w.parents = append(w.parents, p)
+	}
 	return nil
 }
 
-func PrintRepos(repos []LocalRepo) {
-	fmt.Println("\nFound repositories:")
-	fmt.Println("==================")
+// ignored reports whether a directory matches an ignore pattern. Patterns
+// containing a slash match the path relative to the scanned root, the others
+// match the directory name.
+func (w *walker) ignored(rel string, name string) bool {
+	for _, pattern := range w.ignore {
+		target := name
+		if strings.Contains(pattern, "/") {
+			target = rel
+		}
+		if ok, _ := path.Match(strings.TrimSuffix(pattern, "/"), target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isRepoRoot reports whether dir has a .git entry: a directory for regular
+// repositories, a file for submodules and worktrees.
+func isRepoRoot(dir string) bool {
+	_, err := os.Lstat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+func isWithin(p string, dir string) bool {
+	return strings.HasPrefix(p, dir+string(filepath.Separator))
+}
+
+// probeRepos reads the state of every repository, running at most workers
+// git probes at a time; zero uses one per CPU.
+func probeRepos(repos []LocalRepo, workers int) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				probeRepo(&repos[i])
+			}
+		}()
+	}
+	for i := range repos {
+		if repos[i].IsGitRepo {
+			jobs <- i
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func probeRepo(repo *LocalRepo) {
+	if !isGitRepository(repo.Path) {
+		repo.IsGitRepo = false
+		return
+	}
+	if branch, err := gitOutput(repo.Path, "symbolic-ref", "--short", "HEAD"); err == nil {
+		repo.DefaultBranch = branch
+	}
+	if out, err := gitOutput(repo.Path, "log", "-1", "--format=%H%x00%aI%x00%an <%ae>"); err == nil {
+		if fields := strings.Split(out, "\x00"); len(fields) == 3 {
+			repo.LastCommitHash = fields[0]
+			repo.LastCommitDate, _ = time.Parse(time.RFC3339, fields[1])
+			repo.LastCommitAuthor = fields[2]
+		}
+	}
+	if out, err := gitOutput(repo.Path, "remote", "-v"); err == nil {
+		repo.Remotes = parseRemotes(out)
+	}
+	if out, err := gitOutput(repo.Path, "status", "--porcelain=v2", "--branch"); err == nil {
+		parseStatus(repo, out)
+	}
+	if out, err := gitOutput(repo.Path, "stash", "list", "--format=%H"); err == nil {
+		repo.Stashes = countLines(out)
+	}
+	repo.UnpushedBranches = unpushedBranches(repo.Path)
+}
+
+// parseRemotes parses the output of "git remote -v", which lists each remote
+// once for fetching and once for pushing.
+func parseRemotes(out string) []Remote {
+	var remotes []Remote
+	index := map[string]int{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		name, url, kind := fields[0], git.StripCredentials(fields[1]), fields[2]
+		i, ok := index[name]
+		if !ok {
+			i = len(remotes)
+			index[name] = i
+			remotes = append(remotes, Remote{Name: name})
+		}
+		switch kind {
+		case "(fetch)":
+			remotes[i].URL = url
+		case "(push)":
+			remotes[i].PushURL = url
+		}
+	}
+	for i := range remotes {
+		if remotes[i].PushURL == remotes[i].URL {
+			remotes[i].PushURL = ""
+		}
+	}
+	return remotes
+}
+
+// parseStatus reads the upstream, ahead/behind counts and changed files from
+// the output of "git status --porcelain=v2 --branch".
+func parseStatus(repo *LocalRepo, out string) {
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case strings.HasPrefix(line, "# branch.upstream "):
+			repo.Upstream = strings.TrimPrefix(line, "# branch.upstream ")
+		case strings.HasPrefix(line, "# branch.ab "):
+			fmt.Sscanf(strings.TrimPrefix(line, "# branch.ab "), "+%d -%d", &repo.Ahead, &repo.Behind)
+		case strings.HasPrefix(line, "1 "), strings.HasPrefix(line, "2 "), strings.HasPrefix(line, "u "):
+			repo.Modified++
+		case strings.HasPrefix(line, "? "):
+			repo.Untracked++
+		}
+	}
+}
+
+// unpushedBranches returns the local branches with commits that are on no
+// remote-tracking branch, which includes every non-empty branch of a
+// repository without remotes.
+func unpushedBranches(dir string) []string {
+	out, err := gitOutput(dir, "for-each-ref", "--format=%(refname:short)", "refs/heads")
+	if err != nil {
+		return nil
+	}
+	var unpushed []string
+	for _, branch := range strings.Split(out, "\n") {
+		if branch == "" {
+			continue
+		}
+		count, err := gitOutput(dir, "rev-list", "--count", "refs/heads/"+branch, "--not", "--remotes")
+		if err == nil && count != "0" {
+			unpushed = append(unpushed, branch)
+		}
+	}
+	return unpushed
+}
+
+func countLines(out string) int {
+	if out == "" {
+		return 0
+	}
+	return strings.Count(out, "\n") + 1
+}
+
+func isGitRepository(path string) bool {
+	cmd := exec.Command("git", "rev-parse", "--is-inside-work-tree")
+	cmd.Dir = path
+	cmd.Stdout = io.Discard
+	cmd.Stderr = io.Discard
+	return cmd.Run() == nil
+}
+
+func isRepoInMonorepo(repoPath string, monorepoPath string) bool {
+	return repoPath == monorepoPath || isWithin(repoPath, monorepoPath)
+}
+
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func PrintRepos(w io.Writer, repos []LocalRepo) {
+	fmt.Fprintln(w, "\nFound repositories:")
+	fmt.Fprintln(w, "==================")
 	for _, repo := range repos {
 		status := "Not a Git repo"
 		if repo.IsGitRepo {
@@ -38,9 +404,51 @@ func PrintRepos(repos []LocalRepo) {
 			} else {
 				status = fmt.Sprintf("Git repo (branch: %s)", repo.DefaultBranch)
 			}
+			if repo.ParentRepo != "" {
+				status += fmt.Sprintf(", nested in %s", repo.ParentRepo)
+			}
+		}
+		fmt.Fprintf(w, "%s\n  Path: %s\n  Status: %s\n", repo.Name, repo.Path, status)
+		if repo.IsGitRepo {
+			printRepoState(w, repo)
 		}
-		fmt.Printf("%s\n  Path: %s\n  Status: %s\n\n", repo.Name, repo.Path, status)
+		fmt.Fprintln(w)
+	}
+}
+
+func printRepoState(w io.Writer, repo LocalRepo) {
+	if repo.LastCommitHash != "" {
+		fmt.Fprintf(w, "  Last commit: %s %s by %s\n", git.ShortCommit(repo.LastCommitHash), repo.LastCommitDate.Format("2006-01-02"), repo.LastCommitAuthor)
 	}
+	for _, r := range repo.Remotes {
+		fmt.Fprintf(w, "  Remote: %s %s\n", r.Name, r.URL)
+	}
+	if repo.Upstream != "" {
+		fmt.Fprintf(w, "  Upstream: %s (ahead %d, behind %d)\n", repo.Upstream, repo.Ahead, repo.Behind)
+	}
+	if !repo.AtRisk() {
+		return
+	}
+	var risks []string
+	if len(repo.Remotes) == 0 && repo.LastCommitHash != "" {
+		risks = append(risks, "no remote")
+	}
+	if repo.Modified > 0 {
+		risks = append(risks, fmt.Sprintf("%d modified", repo.Modified))
+	}
+	if repo.Untracked > 0 {
+		risks = append(risks, fmt.Sprintf("%d untracked", repo.Untracked))
+	}
+	if repo.Stashes > 0 {
+		risks = append(risks, fmt.Sprintf("%d stashed", repo.Stashes))
+	}
+	if repo.Ahead > 0 {
+		risks = append(risks, fmt.Sprintf("%d commit(s) ahead of %s", repo.Ahead, repo.Upstream))
+	}
+	if len(repo.UnpushedBranches) > 0 {
+		risks = append(risks, "unpushed: "+strings.Join(repo.UnpushedBranches, ", "))
+	}
+	fmt.Fprintf(w, "  At risk: %s\n", strings.Join(risks, "; "))
 }
 
 func SaveReposData(repos []LocalRepo, filename string) error {
@@ -49,4 +457,17 @@ func SaveReposData(repos []LocalRepo, filename string) error {
 		return err
 	}
 	return os.WriteFile(filename, data, 0644)
-} 
\ No newline at end of file
+}
+
+// LoadReposData reads the scan results saved by SaveReposData.
+func LoadReposData(filename string) ([]LocalRepo, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var repos []LocalRepo
+	if err := json.Unmarshal(data, &repos); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", filename, err)
+	}
+	return repos, nil
+}
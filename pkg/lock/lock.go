@@ -0,0 +1,189 @@
+// Package lock reads and writes monorepo.lock, the file at the monorepo root
+// that pins every integrated repository to the exact upstream commit it was
+// added or last updated at.
+package lock
+
+import (
+	"christopherharwell/project_monorepo/pkg/git"
+	"christopherharwell/project_monorepo/pkg/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileName is the name of the lockfile at the monorepo root.
+const FileName = "monorepo.lock"
+
+// Version is the lockfile format written by this package.
+const Version = 1
+
+// File lists the locked repositories of a monorepo.
+type File struct {
+	// Version is the format version of the lockfile
+	Version int `json:"version"`
+
+	// Repos are the locked repositories, ordered by path
+	Repos []Entry `json:"repos"`
+}
+
+// Entry pins one repository to an upstream commit.
+type Entry struct {
+	// Name is the repository name
+	Name string `json:"name"`
+
+	// Provider is the name of the provider instance the repository comes from
+	Provider string `json:"provider,omitempty"`
+
+	// URL is the clone URL of the repository, without credentials
+	URL string `json:"url"`
+
+	// Branch is the upstream branch the repository follows
+	Branch string `json:"branch"`
+
+	// Path is the location of the repository below the repos directory
+	Path string `json:"path"`
+
+	// Method is either types.MethodSubtree or types.MethodSubmodule
+	Method string `json:"method"`
+
+	// Commit is the integrated upstream commit
+	Commit string `json:"commit"`
+
+	// UpdatedAt is when Commit was recorded
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Origin is the local directory the repository was imported from, if any
+	Origin string `json:"origin,omitempty"`
+}
+
+// Load reads the lockfile of the monorepo at dir. A missing lockfile is not
+// an error and yields an empty File.
+func Load(dir string) (File, error) {
+	return Read(filepath.Join(dir, FileName))
+}
+
+// Read reads the lockfile at file, which may live outside a monorepo, for
+// example to rebuild one from scratch. A missing file yields an empty File.
+func Read(file string) (File, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return File{Version: Version}, nil
+	}
+	if err != nil {
+		return File{}, err
+	}
+
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return File{}, fmt.Errorf("error parsing %s: %w", file, err)
+	}
+	if f.Version < 1 || f.Version > Version {
+		return File{}, fmt.Errorf("%s: unsupported version %d", file, f.Version)
+	}
+	return f, nil
+}
+
+// Save writes f to the monorepo at dir as indented JSON, ordered by path.
+func Save(dir string, f File) error {
+	f.Version = Version
+	sort.Slice(f.Repos, func(i, j int) bool {
+		return f.Repos[i].Path < f.Repos[j].Path
+	})
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, FileName), append(data, '\n'), 0644)
+}
+
+// Record pins r to r.Commit, replacing any entry with the same path.
+// Credentials embedded in the clone URL are not recorded.
+//
+// Parameters:
+//   - r: The integrated repository, with Commit set
+//   - useSubtree: Whether the repository is integrated as a subtree instead of a submodule
+//   - at: When the commit was integrated
+func (f *File) Record(r types.Repo, useSubtree bool, at time.Time) {
+	method := types.MethodSubmodule
+	if useSubtree {
+		method = types.MethodSubtree
+	}
+	path := r.Path
+	if path == "" {
+		path = r.Name
+	}
+
+	entry := Entry{
+		Name:      r.Name,
+		Provider:  r.Provider,
+		URL:       git.StripCredentials(r.SSHURL),
+		Branch:    r.DefaultBranch,
+		Path:      path,
+		Method:    method,
+		Commit:    r.Commit,
+		UpdatedAt: at.UTC(),
+		Origin:    r.Origin,
+	}
+	for i := range f.Repos {
+		if f.Repos[i].Path == path {
+			f.Repos[i] = entry
+			return
+		}
+	}
+	f.Repos = append(f.Repos, entry)
+}
+
+// Remove drops the entry locked at path and reports whether there was one.
+func (f *File) Remove(path string) bool {
+	for i, e := range f.Repos {
+		if e.Path == path {
+			f.Repos = append(f.Repos[:i], f.Repos[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the entry locked at path.
+func (f File) Find(path string) (Entry, bool) {
+	for _, e := range f.Repos {
+		if e.Path == path {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Select returns the entries whose path or name equals query, ignoring case,
+// or every entry when query is empty.
+func (f File) Select(query string) []Entry {
+	if query == "" {
+		return f.Repos
+	}
+	var selected []Entry
+	for _, e := range f.Repos {
+		if strings.EqualFold(e.Path, query) || strings.EqualFold(e.Name, query) {
+			selected = append(selected, e)
+		}
+	}
+	return selected
+}
+
+// Repo converts the entry into a repository pinned to the locked commit.
+func (e Entry) Repo() types.Repo {
+	return types.Repo{
+		Name:          e.Name,
+		SSHURL:        e.URL,
+		DefaultBranch: e.Branch,
+		Provider:      e.Provider,
+		Path:          e.Path,
+		Commit:        e.Commit,
+		Origin:        e.Origin,
+	}
+}
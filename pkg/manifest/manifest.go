@@ -0,0 +1,329 @@
+// Package manifest reads and writes monorepo.json, the file at the monorepo
+// root that lists every member repository, and compares it with the
+// repositories actually present in the monorepo.
+package manifest
+
+import (
+	"christopherharwell/project_monorepo/pkg/git"
+	"christopherharwell/project_monorepo/pkg/types"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileName is the name of the manifest file at the monorepo root.
+const FileName = "monorepo.json"
+
+// Version is the manifest format written by this package.
+const Version = 1
+
+// Manifest lists the member repositories of a monorepo.
+type Manifest struct {
+	// Version is the format version of the manifest
+	Version int `json:"version"`
+
+	// Members are the repositories integrated into the monorepo, ordered by path
+	Members []Member `json:"members"`
+}
+
+// Member describes one repository of the monorepo.
+type Member struct {
+	// Name is the repository name
+	Name string `json:"name"`
+
+	// Provider is the name of the provider instance the repository comes from;
+	// it supplies credentials when the repository is cloned
+	Provider string `json:"provider,omitempty"`
+
+	// FullPath is the owner-qualified path of the repository at its provider
+	FullPath string `json:"full_path,omitempty"`
+
+	// URL is the clone URL of the repository, without credentials
+	URL string `json:"url"`
+
+	// Branch is the branch integrated into the monorepo
+	Branch string `json:"branch"`
+
+	// Path is the location of the repository below the repos directory
+	Path string `json:"path"`
+
+	// Method is either types.MethodSubtree or types.MethodSubmodule
+	Method string `json:"method"`
+
+	// Origin is the local directory the repository was imported from, if any
+	Origin string `json:"origin,omitempty"`
+}
+
+// FromRepos builds a manifest listing repos, all integrated with the same method.
+//
+// Parameters:
+//   - repos: The selected repositories, with Path assigned
+//   - useSubtree: Whether the repositories are integrated as subtrees instead of submodules
+//
+// Returns:
+//   - Manifest: The manifest, with members ordered by path
+func FromRepos(repos []types.Repo, useSubtree bool) Manifest {
+	m := Manifest{Version: Version}
+	for _, r := range repos {
+		m.Members = append(m.Members, newMember(r, useSubtree))
+	}
+	m.sort()
+	return m
+}
+
+// Record lists r as a member, replacing any member with the same path.
+//
+// Parameters:
+//   - r: The integrated repository, with Path assigned
+//   - useSubtree: Whether the repository is integrated as a subtree instead of a submodule
+func (m *Manifest) Record(r types.Repo, useSubtree bool) {
+	member := newMember(r, useSubtree)
+	for i := range m.Members {
+		if m.Members[i].Path == member.Path {
+			m.Members[i] = member
+			return
+		}
+	}
+	m.Members = append(m.Members, member)
+	m.sort()
+}
+
+func newMember(r types.Repo, useSubtree bool) Member {
+	method := types.MethodSubmodule
+	if useSubtree {
+		method = types.MethodSubtree
+	}
+	return Member{
+		Name:     r.Name,
+		Provider: r.Provider,
+		FullPath: r.FullPath,
+		URL:      git.StripCredentials(r.SSHURL),
+		Branch:   r.DefaultBranch,
+		Path:     memberPath(r),
+		Method:   method,
+		Origin:   r.Origin,
+	}
+}
+
+func (m *Manifest) sort() {
+	sort.Slice(m.Members, func(i, j int) bool {
+		return m.Members[i].Path < m.Members[j].Path
+	})
+}
+
+func memberPath(r types.Repo) string {
+	if r.Path != "" {
+		return r.Path
+	}
+	return r.Name
+}
+
+// Repo converts the member back into a repository that can be passed to the
+// git package.
+func (m Member) Repo() types.Repo {
+	return types.Repo{
+		Name:          m.Name,
+		SSHURL:        m.URL,
+		DefaultBranch: m.Branch,
+		Provider:      m.Provider,
+		FullPath:      m.FullPath,
+		Path:          m.Path,
+		Origin:        m.Origin,
+	}
+}
+
+// Remove drops the member at path and reports whether there was one.
+func (m *Manifest) Remove(path string) bool {
+	for i, member := range m.Members {
+		if member.Path == path {
+			m.Members = append(m.Members[:i], m.Members[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Load reads and validates the manifest of the monorepo at dir.
+func Load(dir string) (Manifest, error) {
+	file := filepath.Join(dir, FileName)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Manifest{}, fmt.Errorf("error parsing %s: %w", file, err)
+	}
+	if err := m.validate(); err != nil {
+		return Manifest{}, fmt.Errorf("invalid %s: %w", file, err)
+	}
+	return m, nil
+}
+
+func (m Manifest) validate() error {
+	if m.Version < 1 || m.Version > Version {
+		return fmt.Errorf("unsupported version %d", m.Version)
+	}
+
+	paths := map[string]bool{}
+	for i, member := range m.Members {
+		switch {
+		case member.URL == "":
+			return fmt.Errorf("member %d (%s): url is empty", i+1, member.Name)
+		case member.Path == "" || path.IsAbs(member.Path) || path.Clean(member.Path) != member.Path || strings.HasPrefix(member.Path, ".."):
+			return fmt.Errorf("member %d (%s): path %q must be a clean relative path", i+1, member.Name, member.Path)
+		case member.Method != types.MethodSubtree && member.Method != types.MethodSubmodule:
+			return fmt.Errorf("member %d (%s): method must be %q or %q", i+1, member.Name, types.MethodSubtree, types.MethodSubmodule)
+		case paths[member.Path]:
+			return fmt.Errorf("member %d (%s): path %q is listed twice", i+1, member.Name, member.Path)
+		}
+		paths[member.Path] = true
+	}
+	return nil
+}
+
+// Save writes m to the monorepo at dir as indented JSON.
+func Save(dir string, m Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, FileName), append(data, '\n'), 0644)
+}
+
+// Report is the result of comparing a manifest with a monorepo.
+type Report struct {
+	// Missing are the members whose path does not exist in the monorepo
+	Missing []Member
+
+	// Unmanaged are the paths below the repos directory that no member claims
+	Unmanaged []string
+
+	// Drift lists the members that exist but differ from their manifest entry
+	Drift []Drift
+}
+
+// Drift describes how a member present in the monorepo differs from the manifest.
+type Drift struct {
+	Member  Member
+	Problem string
+}
+
+// InSync reports whether the monorepo matches the manifest exactly.
+func (r Report) InSync() bool {
+	return len(r.Missing) == 0 && len(r.Unmanaged) == 0 && len(r.Drift) == 0
+}
+
+// Check compares the manifest with the repos directory of the monorepo at
+// dir. Submodule members are compared with .gitmodules; members recorded
+// with the other integration method are reported as drift.
+//
+// Parameters:
+//   - dir: Path to the monorepo directory
+//   - m: The manifest to compare with
+//   - cloneURL: Resolves the URL a member is cloned from, as written to .gitmodules; nil uses Member.URL
+//
+// Returns:
+//   - Report: The missing, unmanaged and drifted members
+//   - error: Any error that occurred while reading the monorepo
+func Check(dir string, m Manifest, cloneURL func(Member) string) (Report, error) {
+	if cloneURL == nil {
+		cloneURL = func(member Member) string { return member.URL }
+	}
+
+	submodules, err := git.Submodules(dir)
+	if err != nil {
+		return Report{}, err
+	}
+
+	var report Report
+	for _, member := range m.Members {
+		if !git.RepoExists(dir, member.Repo()) {
+			report.Missing = append(report.Missing, member)
+			continue
+		}
+
+		sm, isSubmodule := submodules[path.Join(git.ReposDir, member.Path)]
+		switch {
+		case member.Method == types.MethodSubtree && isSubmodule:
+			report.Drift = append(report.Drift, Drift{member, "registered as a submodule, the manifest says subtree"})
+		case member.Method == types.MethodSubmodule && !isSubmodule:
+			report.Drift = append(report.Drift, Drift{member, "not registered as a submodule, the manifest says submodule"})
+		case isSubmodule && git.StripCredentials(sm.URL) != git.StripCredentials(cloneURL(member)):
+			report.Drift = append(report.Drift, Drift{member, fmt.Sprintf("submodule URL is %s, the manifest has %s", git.RedactURL(sm.URL), member.URL)})
+		case isSubmodule && sm.Branch != "" && sm.Branch != member.Branch:
+			report.Drift = append(report.Drift, Drift{member, fmt.Sprintf("submodule tracks branch %s, the manifest has %s", sm.Branch, member.Branch)})
+		}
+	}
+
+	report.Unmanaged, err = unmanagedPaths(filepath.Join(dir, git.ReposDir), m.Members)
+	if err != nil {
+		return Report{}, err
+	}
+	return report, nil
+}
+
+// unmanagedPaths returns the entries below reposDir that are neither a
+// member path nor a directory leading to one.
+func unmanagedPaths(reposDir string, members []Member) ([]string, error) {
+	memberPaths := map[string]bool{}
+	parents := map[string]bool{}
+	for _, member := range members {
+		memberPaths[member.Path] = true
+		for p := path.Dir(member.Path); p != "."; p = path.Dir(p) {
+			parents[p] = true
+		}
+	}
+
+	var unmanaged []string
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		entries, err := os.ReadDir(filepath.Join(reposDir, filepath.FromSlash(rel)))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			p := path.Join(rel, entry.Name())
+			switch {
+			case memberPaths[p]:
+			case parents[p] && entry.IsDir():
+				if err := walk(p); err != nil {
+					return err
+				}
+			default:
+				unmanaged = append(unmanaged, p)
+			}
+		}
+		return nil
+	}
+	if err := walk(""); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", reposDir, err)
+	}
+	return unmanaged, nil
+}
+
+// PrintReport prints the differences between the manifest and the monorepo to w.
+func PrintReport(w io.Writer, r Report) {
+	if r.InSync() {
+		fmt.Fprintln(w, "The monorepo matches the manifest.")
+		return
+	}
+	for _, member := range r.Missing {
+		fmt.Fprintf(w, "Missing:   %s/%s (%s, %s)\n", git.ReposDir, member.Path, member.URL, member.Method)
+	}
+	for _, p := range r.Unmanaged {
+		fmt.Fprintf(w, "Unmanaged: %s/%s is not listed in %s\n", git.ReposDir, p, FileName)
+	}
+	for _, d := range r.Drift {
+		fmt.Fprintf(w, "Drift:     %s/%s %s\n", git.ReposDir, d.Member.Path, d.Problem)
+	}
+}
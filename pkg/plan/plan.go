@@ -0,0 +1,287 @@
+// Package plan records the changes a command would make to the monorepo,
+// so they can be reviewed with --dry-run before anything is touched.
+package plan
+
+import (
+	"christopherharwell/project_monorepo/pkg/git"
+	"christopherharwell/project_monorepo/pkg/types"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Actions of a plan step.
+const (
+	// ActionInit creates the monorepo directory and its git repository
+	ActionInit = "init"
+
+	// ActionAdd integrates a repository into the monorepo
+	ActionAdd = "add"
+
+	// ActionUpdate pulls the tip of a repository's branch into the monorepo
+	ActionUpdate = "update"
+
+	// ActionPush pushes a subtree back to its repository
+	ActionPush = "push"
+
+	// ActionRemove removes a repository from the monorepo
+	ActionRemove = "remove"
+
+	// ActionWrite writes and commits a file at the monorepo root
+	ActionWrite = "write"
+
+	// ActionRemote creates the remote of an imported local repository and pushes to it
+	ActionRemote = "remote"
+
+	// ActionSkip leaves a repository alone; Reason says why
+	ActionSkip = "skip"
+)
+
+// actionOrder is the order in which the summary counts actions.
+var actionOrder = []string{ActionInit, ActionRemote, ActionAdd, ActionUpdate, ActionPush, ActionRemove, ActionWrite, ActionSkip}
+
+// Plan lists the steps of a command in the order they would run.
+type Plan struct {
+	// Command is the command that produced the plan
+	Command string `json:"command"`
+
+	// Monorepo is the path to the monorepo directory
+	Monorepo string `json:"monorepo"`
+
+	// Steps are the planned changes and skipped repositories
+	Steps []Step `json:"steps"`
+}
+
+// Step is one change of a plan, or a repository that is left alone.
+type Step struct {
+	// Action is one of the Action constants
+	Action string `json:"action"`
+
+	// Name is the repository name; empty for init and write steps
+	Name string `json:"name,omitempty"`
+
+	// Path is the repository or file path relative to the monorepo
+	Path string `json:"path,omitempty"`
+
+	// Method is types.MethodSubtree or types.MethodSubmodule
+	Method string `json:"method,omitempty"`
+
+	// URL is the clone URL of the repository, without credentials
+	URL string `json:"url,omitempty"`
+
+	// Branch is the upstream branch that is added, pulled or pushed
+	Branch string `json:"branch,omitempty"`
+
+	// Commit is the commit a repository is added at, or updated from
+	Commit string `json:"commit,omitempty"`
+
+	// Reason explains skip, write and remote steps
+	Reason string `json:"reason,omitempty"`
+}
+
+// New creates an empty plan for command on the monorepo at dir.
+func New(command string, dir string) *Plan {
+	return &Plan{Command: command, Monorepo: dir, Steps: []Step{}}
+}
+
+// Init plans the creation of the monorepo unless it is already a git repository.
+func (p *Plan) Init() {
+	if git.IsGitInitialized(p.Monorepo) || p.has(ActionInit, "") {
+		return
+	}
+	p.Steps = append(p.Steps, Step{Action: ActionInit, Path: "."})
+}
+
+// Add plans adding repos with the given method. Repositories that already
+// exist, or are added earlier in the plan, are skipped.
+//
+// Parameters:
+//   - repos: The repositories to add; a Commit pins the repository to it
+//   - useSubtree: Whether the repositories are added as subtrees instead of submodules
+//
+// Returns:
+//   - int: The number of repositories that would be added
+func (p *Plan) Add(repos []types.Repo, useSubtree bool) int {
+	added := 0
+	for _, r := range repos {
+		switch {
+		case p.has(ActionAdd, repoPath(r)):
+			p.Skip(r, "added earlier in this plan")
+		case git.RepoExists(p.Monorepo, r):
+			p.Skip(r, repoPath(r)+" already exists")
+		default:
+			p.Steps = append(p.Steps, repoStep(ActionAdd, r, useSubtree))
+			added++
+		}
+	}
+	return added
+}
+
+// Update plans pulling the tip of each repository's branch. Repositories
+// missing from the monorepo are skipped.
+//
+// Parameters:
+//   - repos: The repositories to update, with Commit set to their locked commit
+//   - useSubtree: Whether the repositories are subtrees instead of submodules
+//
+// Returns:
+//   - int: The number of repositories that would be updated
+func (p *Plan) Update(repos []types.Repo, useSubtree bool) int {
+	updated := 0
+	for _, r := range repos {
+		if !git.RepoExists(p.Monorepo, r) {
+			p.Skip(r, repoPath(r)+" does not exist")
+			continue
+		}
+		p.Steps = append(p.Steps, repoStep(ActionUpdate, r, useSubtree))
+		updated++
+	}
+	return updated
+}
+
+// Push plans pushing each subtree back to its branch. Subtrees missing from
+// the monorepo are skipped.
+func (p *Plan) Push(repos []types.Repo) {
+	for _, r := range repos {
+		if !git.RepoExists(p.Monorepo, r) {
+			p.Skip(r, repoPath(r)+" does not exist")
+			continue
+		}
+		p.Steps = append(p.Steps, repoStep(ActionPush, r, true))
+	}
+}
+
+// Remove plans removing r from the monorepo.
+func (p *Plan) Remove(r types.Repo) {
+	step := repoStep(ActionRemove, r, false)
+	step.Method = ""
+	p.Steps = append(p.Steps, step)
+}
+
+// Remote plans creating the remote r.SSHURL for the local repository at
+// r.Origin and pushing r.DefaultBranch to it.
+func (p *Plan) Remote(r types.Repo) {
+	p.Steps = append(p.Steps, Step{
+		Action: ActionRemote,
+		Name:   r.Name,
+		Path:   repoPath(r),
+		URL:    git.StripCredentials(r.SSHURL),
+		Branch: r.DefaultBranch,
+		Reason: "push " + r.Origin,
+	})
+}
+
+// Skip records that r is left alone and why.
+func (p *Plan) Skip(r types.Repo, reason string) {
+	p.Steps = append(p.Steps, Step{
+		Action: ActionSkip,
+		Name:   r.Name,
+		Path:   repoPath(r),
+		URL:    git.StripCredentials(r.SSHURL),
+		Reason: reason,
+	})
+}
+
+// Write plans writing and committing file at the monorepo root. A file is
+// only listed once.
+func (p *Plan) Write(file string, reason string) {
+	if p.has(ActionWrite, file) {
+		return
+	}
+	p.Steps = append(p.Steps, Step{Action: ActionWrite, Path: file, Reason: reason})
+}
+
+// Count returns the number of steps with action.
+func (p Plan) Count(action string) int {
+	n := 0
+	for _, s := range p.Steps {
+		if s.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+func (p Plan) has(action string, path string) bool {
+	for _, s := range p.Steps {
+		if s.Action == action && (path == "" || s.Path == path) {
+			return true
+		}
+	}
+	return false
+}
+
+func repoStep(action string, r types.Repo, useSubtree bool) Step {
+	method := types.MethodSubmodule
+	if useSubtree {
+		method = types.MethodSubtree
+	}
+	return Step{
+		Action: action,
+		Name:   r.Name,
+		Path:   repoPath(r),
+		Method: method,
+		URL:    git.StripCredentials(r.SSHURL),
+		Branch: r.DefaultBranch,
+		Commit: r.Commit,
+	}
+}
+
+func repoPath(r types.Repo) string {
+	return filepath.ToSlash(git.RepoPath(r))
+}
+
+// Print prints the steps of p and a summary of the planned changes to w.
+func Print(w io.Writer, p Plan) {
+	fmt.Fprintf(w, "\nPlan for %s in %s:\n", p.Command, p.Monorepo)
+	if len(p.Steps) == 0 {
+		fmt.Fprintln(w, "  nothing to do")
+	}
+	for _, s := range p.Steps {
+		fmt.Fprintf(w, "  %-7s %-40s %s\n", s.Action, s.Path, s.describe())
+	}
+
+	var counts []string
+	for _, action := range actionOrder {
+		if n := p.Count(action); n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, action))
+		}
+	}
+	if len(counts) == 0 {
+		fmt.Fprintln(w, "Dry run: nothing was changed.")
+		return
+	}
+	fmt.Fprintf(w, "Dry run: %s. Nothing was changed.\n", strings.Join(counts, ", "))
+}
+
+// describe summarizes the details of a step for Print.
+func (s Step) describe() string {
+	switch s.Action {
+	case ActionInit:
+		return "create the monorepo"
+	case ActionSkip, ActionWrite:
+		return s.Reason
+	case ActionRemove:
+		return s.URL
+	case ActionRemote:
+		return fmt.Sprintf("%s (%s) to %s", s.Reason, s.Branch, s.URL)
+	}
+
+	details := fmt.Sprintf("%-9s %s (%s)", s.Method, s.URL, s.Branch)
+	switch {
+	case s.Action == ActionAdd && s.Commit != "":
+		details += " at " + git.ShortCommit(s.Commit)
+	case s.Action == ActionUpdate && s.Commit != "":
+		details += " from " + git.ShortCommit(s.Commit)
+	}
+	return details
+}
+
+// WriteJSON writes p to w as indented JSON.
+func WriteJSON(w io.Writer, p Plan) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
@@ -0,0 +1,235 @@
+// Package provider defines the interface implemented by every repository
+// source and a registry that creates configured provider instances by type.
+package provider
+
+import (
+	"christopherharwell/project_monorepo/pkg/httputil"
+	"christopherharwell/project_monorepo/pkg/types"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// AuthMethod describes how git authenticates against a provider's repositories.
+type AuthMethod string
+
+const (
+	// AuthSSH uses the SSH keys of the local user
+	AuthSSH AuthMethod = "ssh"
+
+	// AuthToken embeds the provider token in HTTPS clone URLs
+	AuthToken AuthMethod = "token"
+)
+
+// Provider is a source of repositories such as a GitHub account or a GitLab instance.
+type Provider interface {
+	// Name returns the unique name of this provider instance
+	Name() string
+
+	// ListRepos returns every repository visible to the configured account
+	ListRepos(ctx context.Context) ([]types.Repo, error)
+
+	// CloneURL returns the URL git should use to clone or push r
+	CloneURL(r types.Repo) string
+
+	// AuthMethod returns how git authenticates against the repositories
+	AuthMethod() AuthMethod
+}
+
+// PageCacher is implemented by providers whose listing requests can be sent
+// as conditional requests, so unchanged pages are served from the cache.
+type PageCacher interface {
+	// SetPageCache makes the next ListRepos send its requests through pages
+	SetPageCache(pages *httputil.PageCache)
+}
+
+// Factory creates a provider instance from its configuration.
+type Factory func(cfg types.ProviderConfig) (Provider, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a provider type available to New. It panics when the type
+// is registered twice, as this is a programming error.
+func Register(typ string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[typ]; exists {
+		panic(fmt.Sprintf("provider: type %q registered twice", typ))
+	}
+	registry[typ] = factory
+}
+
+// Types returns the registered provider types in sorted order.
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var typs []string
+	for typ := range registry {
+		typs = append(typs, typ)
+	}
+	sort.Strings(typs)
+	return typs
+}
+
+// InstanceName returns the name of the provider instance configured by cfg,
+// which defaults to its type.
+func InstanceName(cfg types.ProviderConfig) string {
+	if cfg.Name != "" {
+		return cfg.Name
+	}
+	return cfg.Type
+}
+
+// New creates a provider instance using the factory registered for cfg.Type.
+func New(cfg types.ProviderConfig) (Provider, error) {
+	registryMu.RLock()
+	factory, ok := registry[cfg.Type]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown provider type %q (available: %v)", cfg.Type, Types())
+	}
+	return factory(cfg)
+}
+
+// NewAll creates a provider instance for every configuration and makes sure
+// the instance names are unique.
+//
+// Parameters:
+//   - cfgs: The provider configurations
+//
+// Returns:
+//   - []Provider: The provider instances, in configuration order
+//   - error: Any error that occurred while creating the instances
+func NewAll(cfgs []types.ProviderConfig) ([]Provider, error) {
+	var providers []Provider
+	seen := map[string]bool{}
+	for _, cfg := range cfgs {
+		p, err := New(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if seen[p.Name()] {
+			return nil, fmt.Errorf("duplicate provider name %q, set a unique \"name\" for each provider", p.Name())
+		}
+		seen[p.Name()] = true
+		providers = append(providers, p)
+	}
+	return providers, nil
+}
+
+// AccountKey identifies what a provider instance configured by cfg lists:
+// its type followed by a fingerprint of the whole configuration, so a cached
+// listing is not reused once the token, API URL, account or remotes change.
+// The token cannot be recovered from the key.
+func AccountKey(cfg types.ProviderConfig) string {
+	data, _ := json.Marshal(cfg)
+	sum := sha256.Sum256(data)
+	return cfg.Type + ":" + hex.EncodeToString(sum[:8])
+}
+
+// Result is the listing of one provider.
+type Result struct {
+	// Provider is the provider that was queried
+	Provider Provider
+
+	// Repos are the repositories it listed, each recording the provider name
+	Repos []types.Repo
+
+	// Err is set when the listing failed
+	Err error
+}
+
+// FetchEach lists the repositories of all providers concurrently and returns
+// the listing of each provider separately, in provider order.
+func FetchEach(ctx context.Context, providers []Provider) []Result {
+	results := make([]Result, len(providers))
+
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i].Provider = p
+			repos, err := p.ListRepos(ctx)
+			if err != nil {
+				results[i].Err = err
+				return
+			}
+			for j := range repos {
+				repos[j].Provider = p.Name()
+			}
+			results[i].Repos = repos
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// FetchAll lists the repositories of all providers concurrently. Each
+// returned repository records the name of the provider it came from.
+//
+// Parameters:
+//   - ctx: Context for the requests
+//   - providers: The providers to query
+//
+// Returns:
+//   - []types.Repo: The repositories of every provider that succeeded, in provider order
+//   - error: The joined errors of the providers that failed
+func FetchAll(ctx context.Context, providers []Provider) ([]types.Repo, error) {
+	var allRepos []types.Repo
+	var errs []error
+	for _, r := range FetchEach(ctx, providers) {
+		allRepos = append(allRepos, r.Repos...)
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Provider.Name(), r.Err))
+		}
+	}
+	return allRepos, errors.Join(errs...)
+}
+
+// RewriteHost replaces the host of a clone URL with host, keeping the
+// scheme, user and path. Both URLs ("https://host/owner/repo.git",
+// "ssh://git@host:22/owner/repo.git") and scp-like SSH addresses
+// ("git@host:owner/repo.git") are supported. An empty host leaves the URL unchanged.
+func RewriteHost(rawURL, host string) string {
+	if host == "" {
+		return rawURL
+	}
+
+	if u, err := url.Parse(rawURL); err == nil && u.Scheme != "" && u.Host != "" {
+		u.Host = host
+		return u.String()
+	}
+
+	// scp-like syntax: [user@]host:path
+	at := strings.Index(rawURL, "@") + 1
+	colon := strings.Index(rawURL[at:], ":")
+	if colon < 0 {
+		return rawURL
+	}
+	return rawURL[:at] + host + rawURL[at+colon:]
+}
+
+// CloneURL returns the clone URL of r using the provider it was listed from.
+// Repositories whose provider is not among providers keep their stored URL.
+func CloneURL(providers []Provider, r types.Repo) string {
+	for _, p := range providers {
+		if p.Name() == r.Provider {
+			return p.CloneURL(r)
+		}
+	}
+	return r.SSHURL
+}
@@ -0,0 +1,118 @@
+package provider
+
+import (
+	"christopherharwell/project_monorepo/pkg/types"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fakeProvider returns a fixed list of repositories or a fixed error.
+type fakeProvider struct {
+	name  string
+	repos []types.Repo
+	err   error
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) ListRepos(ctx context.Context) ([]types.Repo, error) {
+	return p.repos, p.err
+}
+
+func (p *fakeProvider) CloneURL(r types.Repo) string { return "fake:" + r.Name }
+
+func (p *fakeProvider) AuthMethod() AuthMethod { return AuthSSH }
+
+func init() {
+	Register("fake", func(cfg types.ProviderConfig) (Provider, error) {
+		return &fakeProvider{name: InstanceName(cfg)}, nil
+	})
+}
+
+func TestNew(t *testing.T) {
+	p, err := New(types.ProviderConfig{Type: "fake", Name: "work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name() != "work" {
+		t.Errorf("Expected name 'work', got '%s'", p.Name())
+	}
+
+	if _, err := New(types.ProviderConfig{Type: "unknown"}); err == nil {
+		t.Error("Expected an error for an unknown provider type")
+	}
+}
+
+func TestNewAllRejectsDuplicateNames(t *testing.T) {
+	_, err := NewAll([]types.ProviderConfig{{Type: "fake"}, {Type: "fake"}})
+	if err == nil {
+		t.Fatal("Expected an error for duplicate provider names")
+	}
+
+	providers, err := NewAll([]types.ProviderConfig{{Type: "fake"}, {Type: "fake", Name: "second"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(providers) != 2 {
+		t.Errorf("Expected 2 providers, got %d", len(providers))
+	}
+}
+
+func TestFetchAll(t *testing.T) {
+	providers := []Provider{
+		&fakeProvider{name: "one", repos: []types.Repo{{Name: "a"}, {Name: "b"}}},
+		&fakeProvider{name: "broken", err: errors.New("boom")},
+		&fakeProvider{name: "two", repos: []types.Repo{{Name: "c"}}},
+	}
+
+	repos, err := FetchAll(context.Background(), providers)
+	if err == nil || !strings.Contains(err.Error(), "broken: boom") {
+		t.Errorf("Expected the broken provider's error, got %v", err)
+	}
+	if len(repos) != 3 {
+		t.Fatalf("Expected 3 repos, got %d", len(repos))
+	}
+	if repos[0].Provider != "one" || repos[2].Provider != "two" {
+		t.Errorf("Expected provider names to be recorded, got %+v", repos)
+	}
+
+	if got := CloneURL(providers, repos[2]); got != "fake:c" {
+		t.Errorf("Expected clone URL from provider 'two', got '%s'", got)
+	}
+	if got := CloneURL(providers, types.Repo{Name: "d", SSHURL: "git@example.com:d.git"}); got != "git@example.com:d.git" {
+		t.Errorf("Expected stored URL for unknown provider, got '%s'", got)
+	}
+}
+
+func TestRewriteHost(t *testing.T) {
+	tests := []struct {
+		url, host, want string
+	}{
+		{"git@github.example.com:team/repo.git", "ssh.github.example.com", "git@ssh.github.example.com:team/repo.git"},
+		{"https://gitlab.internal/group/repo.git", "gitlab.example.com", "https://gitlab.example.com/group/repo.git"},
+		{"ssh://git@gitea.lan:2222/me/repo.git", "gitea.example.com:2222", "ssh://git@gitea.example.com:2222/me/repo.git"},
+		{"git@github.com:me/repo.git", "", "git@github.com:me/repo.git"},
+	}
+	for _, tt := range tests {
+		if got := RewriteHost(tt.url, tt.host); got != tt.want {
+			t.Errorf("RewriteHost(%q, %q) = %q, want %q", tt.url, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestAccountKey(t *testing.T) {
+	cfg := types.ProviderConfig{Type: "github", Token: "secret-token"}
+	key := AccountKey(cfg)
+	if !strings.HasPrefix(key, "github:") || strings.Contains(key, "secret-token") {
+		t.Errorf("AccountKey = %q, want a github key without the token", key)
+	}
+	if AccountKey(cfg) != key {
+		t.Error("AccountKey is not stable")
+	}
+	cfg.Token = "other-token"
+	if AccountKey(cfg) == key {
+		t.Error("AccountKey did not change with the token")
+	}
+}
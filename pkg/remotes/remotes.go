@@ -0,0 +1,122 @@
+// Package remotes implements a provider for repositories that live outside
+// any forge, such as bare repositories on a file share or a NAS reachable
+// over SSH. The repositories are listed statically in the configuration.
+package remotes
+
+import (
+	"bufio"
+	"bytes"
+	"christopherharwell/project_monorepo/pkg/provider"
+	"christopherharwell/project_monorepo/pkg/types"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+func init() {
+	provider.Register("git", NewProvider)
+}
+
+// remotesProvider returns the configured remotes as repositories.
+type remotesProvider struct {
+	name    string
+	remotes []types.RemoteConfig
+}
+
+// NewProvider creates a provider for the remotes listed in cfg.Remotes.
+func NewProvider(cfg types.ProviderConfig) (provider.Provider, error) {
+	name := provider.InstanceName(cfg)
+	for i, r := range cfg.Remotes {
+		if r.URL == "" {
+			return nil, fmt.Errorf("provider %q: remote %d has no url", name, i)
+		}
+	}
+	return &remotesProvider{name: name, remotes: cfg.Remotes}, nil
+}
+
+func (p *remotesProvider) Name() string {
+	return p.name
+}
+
+// ListRepos returns a repository per configured remote. Remotes without a
+// name are named after the last path element of their URL, and remotes
+// without a branch use the branch HEAD points to on the remote. Remotes
+// whose branch cannot be detected are left out with a warning on stderr, and
+// the other remotes are returned together with the joined errors, so callers
+// can tell a partial listing from a complete one.
+func (p *remotesProvider) ListRepos(ctx context.Context) ([]types.Repo, error) {
+	var repos []types.Repo
+	var errs []error
+	for _, r := range p.remotes {
+		repo := types.Repo{
+			Name:          r.Name,
+			SSHURL:        r.URL,
+			DefaultBranch: r.Branch,
+		}
+		if repo.Name == "" {
+			repo.Name = NameFromURL(r.URL)
+		}
+		if repo.DefaultBranch == "" {
+			branch, err := DetectDefaultBranch(ctx, r.URL)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: skipping remote %s: %v\n", r.URL, err)
+				errs = append(errs, fmt.Errorf("remote %s: %w", r.URL, err))
+				continue
+			}
+			repo.DefaultBranch = branch
+		}
+		repos = append(repos, repo)
+	}
+	return repos, errors.Join(errs...)
+}
+
+// CloneURL returns the configured URL of r unchanged.
+func (p *remotesProvider) CloneURL(r types.Repo) string {
+	return r.SSHURL
+}
+
+// AuthMethod reports SSH; remotes are accessed with the local user's git setup.
+func (p *remotesProvider) AuthMethod() provider.AuthMethod {
+	return provider.AuthSSH
+}
+
+// NameFromURL derives a repository name from a git URL or path, e.g.
+// "nas:/volume1/git/notes.git" and "/srv/git/notes/" both yield "notes".
+func NameFromURL(url string) string {
+	url = strings.TrimRight(url, "/")
+	if i := strings.LastIndexAny(url, "/:"); i >= 0 {
+		url = url[i+1:]
+	}
+	return strings.TrimSuffix(path.Base(url), ".git")
+}
+
+// DetectDefaultBranch asks the remote which branch its HEAD points to using
+// `git ls-remote --symref <url> HEAD`.
+func DetectDefaultBranch(ctx context.Context, url string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "ls-remote", "--symref", url, "HEAD")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git ls-remote failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	// The symref line looks like "ref: refs/heads/main\tHEAD"
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "ref: ") {
+			continue
+		}
+		ref, _, _ := strings.Cut(strings.TrimPrefix(line, "ref: "), "\t")
+		return strings.TrimPrefix(ref, "refs/heads/"), nil
+	}
+	return "", fmt.Errorf("could not determine the default branch, set \"branch\" explicitly")
+}
@@ -0,0 +1,102 @@
+package remotes
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"christopherharwell/project_monorepo/pkg/types"
+)
+
+// newBareRepo creates a bare repository whose HEAD points at branch and
+// which has a single commit on that branch.
+func newBareRepo(t *testing.T, branch string) string {
+	dir := t.TempDir()
+	bare := filepath.Join(dir, "tools.git")
+	work := filepath.Join(dir, "work")
+
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Env = append(cmd.Environ(), "GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com", "GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	run("init", "--bare", "-b", branch, bare)
+	run("init", "-b", branch, work)
+	run("-C", work, "commit", "--allow-empty", "-m", "Initial commit")
+	run("-C", work, "push", bare, branch)
+	return bare
+}
+
+func TestListRepos(t *testing.T) {
+	bare := newBareRepo(t, "trunk")
+
+	p, err := NewProvider(types.ProviderConfig{Type: "git", Name: "remotes", Remotes: []types.RemoteConfig{
+		{URL: bare},
+		{URL: "nas:/volume1/git/notes.git", Name: "my-notes", Branch: "main"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repos, err := p.ListRepos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []types.Repo{
+		{Name: "tools", SSHURL: bare, DefaultBranch: "trunk"},
+		{Name: "my-notes", SSHURL: "nas:/volume1/git/notes.git", DefaultBranch: "main"},
+	}
+	if len(repos) != len(want) {
+		t.Fatalf("Expected %d repos, got %d", len(want), len(repos))
+	}
+	for i := range want {
+		if repos[i].Name != want[i].Name || repos[i].SSHURL != want[i].SSHURL || repos[i].DefaultBranch != want[i].DefaultBranch {
+			t.Errorf("Repo %d: expected %+v, got %+v", i, want[i], repos[i])
+		}
+	}
+}
+
+func TestListReposUnreachableRemote(t *testing.T) {
+	bare := newBareRepo(t, "main")
+	missing := filepath.Join(t.TempDir(), "missing.git")
+
+	p, err := NewProvider(types.ProviderConfig{Type: "git", Remotes: []types.RemoteConfig{
+		{URL: missing},
+		{URL: bare},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The reachable remote is returned, but the listing is reported as failed
+	repos, err := p.ListRepos(context.Background())
+	if err == nil || !strings.Contains(err.Error(), missing) {
+		t.Errorf("Expected an error naming %s, got %v", missing, err)
+	}
+	if len(repos) != 1 || repos[0].SSHURL != bare || repos[0].DefaultBranch != "main" {
+		t.Errorf("Expected only %s on main, got %+v", bare, repos)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.ListRepos(ctx); err == nil {
+		t.Error("Expected an error when the context is canceled")
+	}
+}
+
+func TestNameFromURL(t *testing.T) {
+	tests := map[string]string{
+		"nas:/volume1/git/notes.git":       "notes",
+		"ssh://git@nas:2222/srv/tools.git": "tools",
+		"/mnt/share/repos/scripts/":        "scripts",
+		"nas:dotfiles.git":                 "dotfiles",
+	}
+	for url, want := range tests {
+		if got := NameFromURL(url); got != want {
+			t.Errorf("NameFromURL(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
@@ -31,4 +31,160 @@ type Config struct {
 
 	// MonorepoPath is the path to the monorepo where repositories will be integrated
 	MonorepoPath string `json:"monorepo_path"`
-} 
\ No newline at end of file
+
+	// Scan controls how BaseDir is scanned for local repositories
+	Scan ScanConfig `json:"scan"`
+
+	// ImportRemote is the URL of the remote created for a local repository
+	// imported as a submodule; {name} is replaced by the repository name
+	// (e.g., "nas:/volume1/git/{name}.git"). Local paths that do not exist
+	// yet are created as bare repositories.
+	ImportRemote string `json:"import_remote"`
+
+	// CacheTTL is how long the cached listing of a provider is used before it
+	// is fetched again, as a duration such as "12h". It defaults to 24 hours;
+	// "0" keeps listings until they are refreshed explicitly.
+	CacheTTL string `json:"cache_ttl"`
+
+	// MaxPages limits how many pages are fetched from each paginated provider
+	// listing endpoint. Zero means no limit.
+	MaxPages int `json:"max_pages"`
+
+	// GitLabKeysetPagination requests keyset instead of offset pagination when
+	// listing GitLab projects, which large instances require for deep listings
+	GitLabKeysetPagination bool `json:"gitlab_keyset_pagination"`
+
+	// PathLayout is the template for the path of each repository below the
+	// monorepo's repos directory. It supports the {provider}, {owner}, {name}
+	// and {full_path} placeholders and defaults to "{name}".
+	PathLayout string `json:"path_layout"`
+
+	// Filters selects which of the fetched repositories are offered for
+	// selection and added in auto mode
+	Filters FilterConfig `json:"filters"`
+
+	// Providers lists the repository sources to fetch from. When empty, GitHub
+	// and GitLab providers are derived from GitHubToken and GitLabToken.
+	Providers []ProviderConfig `json:"providers"`
+
+	// Remotes lists plain git remotes that are not hosted on any forge.
+	// They are served by an additional provider named "remotes".
+	Remotes []RemoteConfig `json:"remotes"`
+}
+
+// ProviderConfig configures one repository source. Several instances of the
+// same type can be configured as long as their names differ.
+type ProviderConfig struct {
+	// Type selects the provider implementation (e.g., "github", "gitlab")
+	Type string `json:"type"`
+
+	// Name identifies this instance; it defaults to Type
+	Name string `json:"name"`
+
+	// Token is the access token used for API requests and, where needed, git operations.
+	// For Bitbucket it holds the app password.
+	Token string `json:"token"`
+
+	// Username is the account name for providers using basic authentication (e.g., Bitbucket)
+	Username string `json:"username"`
+
+	// Organization is the organization whose repositories are listed (e.g., for Azure DevOps)
+	Organization string `json:"organization"`
+
+	// APIURL is the root of the provider's REST API (e.g., "https://github.example.com/api/v3").
+	// It defaults to the public service and is required for self-hosted providers such as Gitea.
+	APIURL string `json:"api_url"`
+
+	// GitHost replaces the host of the clone URLs returned by the API, for instances
+	// whose git traffic goes through a different host name than the API
+	GitHost string `json:"git_host"`
+
+	// MaxPages limits how many pages are fetched per listing endpoint.
+	// Zero falls back to Config.MaxPages.
+	MaxPages int `json:"max_pages"`
+
+	// KeysetPagination requests keyset pagination from providers that support it
+	KeysetPagination bool `json:"keyset_pagination"`
+
+	// Remotes lists the repositories served by a "git" provider
+	Remotes []RemoteConfig `json:"remotes"`
+}
+
+// RemoteConfig describes a repository reachable through a plain git URL,
+// such as a bare repository on a file share or a NAS over SSH.
+type RemoteConfig struct {
+	// URL is anything git can clone from (e.g., "nas:/volume1/git/notes.git", "/mnt/share/tools.git")
+	URL string `json:"url"`
+
+	// Name is the repository name; it defaults to the last path element of URL
+	Name string `json:"name"`
+
+	// Branch is the branch to integrate; it defaults to the remote's HEAD
+	Branch string `json:"branch"`
+}
+
+// ScanConfig controls the local repository scan.
+type ScanConfig struct {
+	// Ignore lists glob patterns of directories that are not scanned. Patterns
+	// containing a slash match the path relative to the scanned directory, the
+	// others match the directory name. When unset, dependency and build
+	// directories such as node_modules, vendor and .venv are ignored; an empty
+	// list scans everything.
+	Ignore []string `json:"ignore"`
+
+	// MaxDepth limits how many directory levels below the scanned directory
+	// are visited. Zero means no limit.
+	MaxDepth int `json:"max_depth"`
+
+	// Nested searches repositories for nested repositories and submodules
+	// instead of treating every repository root as a leaf
+	Nested bool `json:"nested"`
+
+	// Workers is the number of git probes run concurrently. Zero uses one per CPU.
+	Workers int `json:"workers"`
+}
+
+// FilterConfig holds the include and exclude rules applied to the fetched
+// repositories. A repository is kept when it matches any include rule, or
+// there are none, and matches no exclude rule.
+type FilterConfig struct {
+	// Include lists the rules a repository must match one of to be kept
+	Include []FilterRule `json:"include"`
+
+	// Exclude lists the rules that drop a repository
+	Exclude []FilterRule `json:"exclude"`
+}
+
+// FilterRule matches repositories whose fields satisfy every condition set
+// in the rule; unset conditions match anything. Name, Owner and Provider are
+// case-insensitive glob patterns where "*" matches any sequence of
+// characters, or regular expressions when prefixed with "re:".
+type FilterRule struct {
+	// Name matches the repository name
+	Name string `json:"name"`
+
+	// Owner matches the owner, organization or namespace path
+	Owner string `json:"owner"`
+
+	// Provider matches the name of the provider instance
+	Provider string `json:"provider"`
+
+	// Fork matches forks when true and non-forks when false
+	Fork *bool `json:"fork"`
+
+	// Archived matches archived repositories when true and active ones when false
+	Archived *bool `json:"archived"`
+
+	// Visibility matches "public", "private" or "internal"
+	Visibility string `json:"visibility"`
+
+	// Language matches the primary language, ignoring case
+	Language string `json:"language"`
+
+	// Topics matches repositories having at least one of the topics
+	Topics []string `json:"topics"`
+
+	// PushedBefore matches repositories last pushed before this date,
+	// given as "2006-01-02" or in RFC 3339 format
+	PushedBefore string `json:"pushed_before"`
+}
@@ -1,15 +1,83 @@
 package types
 
+import "time"
+
 // Repo represents a Git repository with its essential metadata.
 // This type is used to standardize repository information across different
-// Git providers (GitHub, GitLab) and local repositories.
+// Git providers (GitHub, GitLab) and local repositories. Providers fill in
+// as much of the descriptive metadata as their APIs return; fields a
+// provider does not know are left at their zero value.
 type Repo struct {
 	// Name is the repository name without the owner/organization prefix
 	Name string
 
+	// ID is the provider's identifier of the repository, which survives renames
+	ID string
+
 	// SSHURL is the Git SSH URL used for cloning the repository
 	SSHURL string
 
 	// DefaultBranch is the name of the repository's default branch (e.g., "main", "master")
 	DefaultBranch string
-} 
\ No newline at end of file
+
+	// Provider is the name of the provider instance the repository was listed from
+	Provider string
+
+	// Owner is the user, organization or namespace path owning the repository
+	Owner string
+
+	// FullPath is the owner-qualified path of the repository (e.g., "octocat/hello-world")
+	FullPath string
+
+	// HTTPSURL is the HTTPS clone URL of the repository
+	HTTPSURL string
+
+	// WebURL is the address of the repository's web page
+	WebURL string
+
+	// Description is the repository description
+	Description string
+
+	// Language is the primary programming language as detected by the provider
+	Language string
+
+	// Topics are the topics or tags assigned to the repository
+	Topics []string
+
+	// Visibility is "public", "private" or "internal"
+	Visibility string
+
+	// Fork reports whether the repository is a fork of another repository
+	Fork bool
+
+	// Archived reports whether the repository is archived (read-only)
+	Archived bool
+
+	// Stars is the number of stars the repository has
+	Stars int
+
+	// CreatedAt is when the repository was created
+	CreatedAt time.Time
+
+	// PushedAt is when the repository last received a push or activity
+	PushedAt time.Time
+
+	// Path is the location of the repository below the monorepo's repos
+	// directory, assigned from the path layout. It defaults to Name when empty.
+	Path string
+
+	// Commit is the upstream commit integrated into the monorepo. When set
+	// before adding, the repository is pinned to it instead of the branch tip.
+	Commit string
+
+	// Origin is the local directory the repository was imported from, for
+	// repositories that did not come from a provider
+	Origin string
+}
+
+// Integration methods of a repository in the monorepo, as recorded in its
+// lockfile and manifest.
+const (
+	MethodSubtree   = "subtree"
+	MethodSubmodule = "submodule"
+)